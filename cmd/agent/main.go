@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -28,6 +29,7 @@ func main() {
 
 	client := &http.Client{
 		Transport: &http.Transport{},
+		Timeout:   30 * time.Second,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -35,11 +37,16 @@ func main() {
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
 	done := make(chan struct{})
 
 	go func() {
 		defer close(done)
-		agent.RuntimeAgent(ctx, client)
+		if err := agent.RuntimeAgent(ctx, client, sighup); err != nil {
+			logger.Log.Fatal("Failed to configure agent", zap.Error(err))
+		}
 	}()
 
 	select {
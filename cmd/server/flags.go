@@ -8,6 +8,8 @@ import (
 
 	"alerting-service/internal/config"
 	"alerting-service/internal/logger"
+	"alerting-service/internal/repository"
+	"alerting-service/internal/signature"
 
 	"go.uber.org/zap"
 )
@@ -22,6 +24,21 @@ var (
 	flagHashKey            string
 	flagCryptoKey          string
 	flagConfigFile         string
+	flagAlertRules         string
+	flagTrustedSubnet      string
+	flagTLSCert            string
+	flagTLSKey             string
+	flagTLSClientCA        string
+	flagTokensFile         string
+	flagTokensDB           bool
+	flagMaxClockSkew       time.Duration
+	flagSampleRetention    time.Duration
+
+	// flagsSet holds the flags set on the command line; config reloads do
+	// not override them.
+	flagsSet map[string]bool
+	// configFile is the config file in use, re-read on reload.
+	configFile string
 )
 
 func parseFlags() error {
@@ -34,13 +51,27 @@ func parseFlags() error {
 	flag.StringVar(&flagDBConnectionString, "d", "", "connection string for postgres db")
 	flag.StringVar(&flagHashKey, "k", "", "hash key string for generation signature")
 	flag.StringVar(&flagCryptoKey, "crypto-key", "", "path to crypto key file")
+	flag.StringVar(&flagAlertRules, "alert-rules", "", "path to alert rules file")
+	flag.StringVar(&flagTLSCert, "tls-cert", "", "path to the TLS certificate, enables HTTPS")
+	flag.StringVar(&flagTLSKey, "tls-key", "", "path to the TLS certificate key")
+	flag.StringVar(&flagTLSClientCA, "tls-client-ca", "", "path to the CA bundle verifying agent certificates, empty to not require them")
+	flag.StringVar(&flagTokensFile, "tokens-file", "", "path to the API tokens file, enables token authentication")
+	flag.BoolVar(&flagTokensDB, "tokens-db", false, "read API tokens from the database, enables token authentication")
+	flag.DurationVar(&flagMaxClockSkew, "max-clock-skew", signature.DefaultMaxClockSkew, "maximum difference between the signed request time and the server clock")
+	flag.DurationVar(&flagSampleRetention, "sample-retention", repository.DefaultSampleRetention, "how long the metric history is kept")
+	flag.StringVar(&flagTrustedSubnet, "t", "", "CIDR of the agents allowed to send metrics, empty to allow any; checked against the client-supplied X-Real-IP header, so run behind a proxy that overwrites it")
 	flag.BoolVar(&flagRestore, "r", true, "restore or not data from file after running server")
 	flag.Parse()
 
+	flagsSet = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		flagsSet[f.Name] = true
+	})
+
 	var serverConfig *config.ServerConfig
-	configFile := flagConfigFile
+	configFile = flagConfigFile
 
-	if envConfigFile := os.Getenv("CONFIG"); envConfigFile != "" {
+	if envConfigFile := os.Getenv("CONFIG"); envConfigFile != "" && !flagsSet["c"] && !flagsSet["config"] {
 		configFile = envConfigFile
 	}
 
@@ -53,13 +84,99 @@ func parseFlags() error {
 		}
 	}
 
-	applyServerConfig(serverConfig)
+	applyServerConfig(serverConfig, flagsSet)
+
+	return nil
+}
+
+// serverFlags is a snapshot of the resolved options.
+type serverFlags struct {
+	RunAddr            string
+	LogLevel           string
+	StoreInterval      int
+	FileStoragePath    string
+	Restore            bool
+	DBConnectionString string
+	HashKey            string
+	CryptoKey          string
+	AlertRules         string
+	TrustedSubnet      string
+	TLSCert            string
+	TLSKey             string
+	TLSClientCA        string
+	TokensFile         string
+	TokensDB           bool
+	MaxClockSkew       time.Duration
+	SampleRetention    time.Duration
+}
+
+func currentFlags() serverFlags {
+	return serverFlags{
+		RunAddr:            flagRunAddr,
+		LogLevel:           flagLogLevel,
+		StoreInterval:      flagStoreInterval,
+		FileStoragePath:    flagFileStoragePath,
+		Restore:            flagRestore,
+		DBConnectionString: flagDBConnectionString,
+		HashKey:            flagHashKey,
+		CryptoKey:          flagCryptoKey,
+		AlertRules:         flagAlertRules,
+		TrustedSubnet:      flagTrustedSubnet,
+		TLSCert:            flagTLSCert,
+		TLSKey:             flagTLSKey,
+		TLSClientCA:        flagTLSClientCA,
+		TokensFile:         flagTokensFile,
+		TokensDB:           flagTokensDB,
+		MaxClockSkew:       flagMaxClockSkew,
+		SampleRetention:    flagSampleRetention,
+	}
+}
+
+func (f serverFlags) restore() {
+	flagRunAddr = f.RunAddr
+	flagLogLevel = f.LogLevel
+	flagStoreInterval = f.StoreInterval
+	flagFileStoragePath = f.FileStoragePath
+	flagRestore = f.Restore
+	flagDBConnectionString = f.DBConnectionString
+	flagHashKey = f.HashKey
+	flagCryptoKey = f.CryptoKey
+	flagAlertRules = f.AlertRules
+	flagTrustedSubnet = f.TrustedSubnet
+	flagTLSCert = f.TLSCert
+	flagTLSKey = f.TLSKey
+	flagTLSClientCA = f.TLSClientCA
+	flagTokensFile = f.TokensFile
+	flagTokensDB = f.TokensDB
+	flagMaxClockSkew = f.MaxClockSkew
+	flagSampleRetention = f.SampleRetention
+}
+
+// reloadFlags re-reads the config file and resolves the options that were
+// not set on the command line again, starting from their defaults so that
+// options removed from the file fall back to them. On error the options are
+// left unchanged.
+func reloadFlags() error {
+	serverConfig, err := config.LoadServerConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"a", "l", "i", "f", "d", "k", "crypto-key", "alert-rules", "t", "tls-cert", "tls-key", "tls-client-ca", "tokens-file", "tokens-db", "max-clock-skew", "sample-retention", "r"} {
+		if f := flag.Lookup(name); f != nil && !flagsSet[name] {
+			_ = f.Value.Set(f.DefValue)
+		}
+	}
+	applyServerConfig(serverConfig, flagsSet)
 
 	return nil
 }
 
-func applyServerConfig(serverConfig *config.ServerConfig) {
-	if flagRunAddr == "" {
+// applyServerConfig applies the environment variables and then the config
+// file to the options that were not set with a flag, so a flag takes
+// precedence over the environment and the environment over the file.
+func applyServerConfig(serverConfig *config.ServerConfig, set map[string]bool) {
+	if !set["a"] {
 		if envRunAddr := os.Getenv("ADDRESS"); envRunAddr != "" {
 			flagRunAddr = envRunAddr
 		} else if serverConfig != nil && serverConfig.Address != "" {
@@ -67,7 +184,7 @@ func applyServerConfig(serverConfig *config.ServerConfig) {
 		}
 	}
 
-	if flagLogLevel == "" {
+	if !set["l"] {
 		if envLogLevel := os.Getenv("LOG_LEVEL"); envLogLevel != "" {
 			flagLogLevel = envLogLevel
 		} else if serverConfig != nil && serverConfig.LogLevel != "" {
@@ -75,17 +192,17 @@ func applyServerConfig(serverConfig *config.ServerConfig) {
 		}
 	}
 
-	if flagStoreInterval == 0 {
+	if !set["i"] {
 		if envStoreInterval := os.Getenv("STORE_INTERVAL"); envStoreInterval != "" {
 			if val, err := strconv.Atoi(envStoreInterval); err == nil {
 				flagStoreInterval = val
 			}
 		} else if serverConfig != nil && serverConfig.StoreInterval != 0 {
-			flagStoreInterval = int(time.Duration(serverConfig.StoreInterval).Seconds())
+			flagStoreInterval = int(serverConfig.StoreInterval.Duration().Seconds())
 		}
 	}
 
-	if flagFileStoragePath == "" {
+	if !set["f"] {
 		if envFileStoragePath := os.Getenv("FILE_STORAGE_PATH"); envFileStoragePath != "" {
 			flagFileStoragePath = envFileStoragePath
 		} else if serverConfig != nil && serverConfig.StoreFile != "" {
@@ -93,7 +210,7 @@ func applyServerConfig(serverConfig *config.ServerConfig) {
 		}
 	}
 
-	if flagDBConnectionString == "" {
+	if !set["d"] {
 		if envDBConnectionString := os.Getenv("DATABASE_DSN"); envDBConnectionString != "" {
 			flagDBConnectionString = envDBConnectionString
 		} else if serverConfig != nil && serverConfig.DatabaseDSN != "" {
@@ -101,7 +218,7 @@ func applyServerConfig(serverConfig *config.ServerConfig) {
 		}
 	}
 
-	if flagHashKey == "" {
+	if !set["k"] {
 		if envHashKey := os.Getenv("KEY"); envHashKey != "" && envHashKey != "none" {
 			flagHashKey = envHashKey
 		} else if serverConfig != nil && serverConfig.HashKey != "" {
@@ -109,7 +226,7 @@ func applyServerConfig(serverConfig *config.ServerConfig) {
 		}
 	}
 
-	if flagCryptoKey == "" {
+	if !set["crypto-key"] {
 		if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 			flagCryptoKey = envCryptoKey
 		} else if serverConfig != nil && serverConfig.CryptoKey != "" {
@@ -117,11 +234,91 @@ func applyServerConfig(serverConfig *config.ServerConfig) {
 		}
 	}
 
-	if envRestore := os.Getenv("RESTORE"); envRestore != "" {
-		if boolValue, err := strconv.ParseBool(envRestore); err == nil {
-			flagRestore = boolValue
+	if !set["alert-rules"] {
+		if envAlertRules := os.Getenv("ALERT_RULES"); envAlertRules != "" {
+			flagAlertRules = envAlertRules
+		} else if serverConfig != nil && serverConfig.AlertRules != "" {
+			flagAlertRules = serverConfig.AlertRules
+		}
+	}
+
+	if !set["t"] {
+		if envTrustedSubnet := os.Getenv("TRUSTED_SUBNET"); envTrustedSubnet != "" {
+			flagTrustedSubnet = envTrustedSubnet
+		} else if serverConfig != nil && serverConfig.TrustedSubnet != "" {
+			flagTrustedSubnet = serverConfig.TrustedSubnet
+		}
+	}
+
+	if !set["tls-cert"] {
+		if envTLSCert := os.Getenv("TLS_CERT"); envTLSCert != "" {
+			flagTLSCert = envTLSCert
+		} else if serverConfig != nil && serverConfig.TLSCert != "" {
+			flagTLSCert = serverConfig.TLSCert
+		}
+	}
+
+	if !set["tls-key"] {
+		if envTLSKey := os.Getenv("TLS_KEY"); envTLSKey != "" {
+			flagTLSKey = envTLSKey
+		} else if serverConfig != nil && serverConfig.TLSKey != "" {
+			flagTLSKey = serverConfig.TLSKey
+		}
+	}
+
+	if !set["tls-client-ca"] {
+		if envTLSClientCA := os.Getenv("TLS_CLIENT_CA"); envTLSClientCA != "" {
+			flagTLSClientCA = envTLSClientCA
+		} else if serverConfig != nil && serverConfig.TLSClientCA != "" {
+			flagTLSClientCA = serverConfig.TLSClientCA
+		}
+	}
+
+	if !set["tokens-file"] {
+		if envTokensFile := os.Getenv("TOKENS_FILE"); envTokensFile != "" {
+			flagTokensFile = envTokensFile
+		} else if serverConfig != nil && serverConfig.TokensFile != "" {
+			flagTokensFile = serverConfig.TokensFile
+		}
+	}
+
+	if !set["tokens-db"] {
+		if envTokensDB := os.Getenv("TOKENS_DB"); envTokensDB != "" {
+			if boolValue, err := strconv.ParseBool(envTokensDB); err == nil {
+				flagTokensDB = boolValue
+			}
+		} else if serverConfig != nil && serverConfig.TokensDB {
+			flagTokensDB = serverConfig.TokensDB
+		}
+	}
+
+	if !set["max-clock-skew"] {
+		if envMaxClockSkew := os.Getenv("MAX_CLOCK_SKEW"); envMaxClockSkew != "" {
+			if val, err := time.ParseDuration(envMaxClockSkew); err == nil {
+				flagMaxClockSkew = val
+			}
+		} else if serverConfig != nil && serverConfig.MaxClockSkew != 0 {
+			flagMaxClockSkew = serverConfig.MaxClockSkew.Duration()
+		}
+	}
+
+	if !set["sample-retention"] {
+		if envSampleRetention := os.Getenv("SAMPLE_RETENTION"); envSampleRetention != "" {
+			if val, err := time.ParseDuration(envSampleRetention); err == nil {
+				flagSampleRetention = val
+			}
+		} else if serverConfig != nil && serverConfig.SampleRetention != 0 {
+			flagSampleRetention = serverConfig.SampleRetention.Duration()
+		}
+	}
+
+	if !set["r"] {
+		if envRestore := os.Getenv("RESTORE"); envRestore != "" {
+			if boolValue, err := strconv.ParseBool(envRestore); err == nil {
+				flagRestore = boolValue
+			}
+		} else if serverConfig != nil {
+			flagRestore = serverConfig.Restore
 		}
-	} else if serverConfig != nil {
-		flagRestore = serverConfig.Restore
 	}
 }
@@ -3,6 +3,9 @@ package main
 import (
 	"os"
 	"testing"
+	"time"
+
+	"alerting-service/internal/config"
 )
 
 func TestParseFlags_FromEnv(t *testing.T) {
@@ -51,3 +54,34 @@ func TestParseFlags_FromEnv(t *testing.T) {
 		t.Errorf("flagRestore = %v; want false", flagRestore)
 	}
 }
+
+func TestApplyServerConfig_Precedence(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("ADDRESS", "env:8080")
+
+	flagRunAddr = "flag:8080"
+	flagLogLevel = "info"
+	flagFileStoragePath = "./backup"
+
+	serverConfig := &config.ServerConfig{
+		Address:       "file:8080",
+		LogLevel:      "warn",
+		StoreInterval: config.Duration(30 * time.Second),
+		StoreFile:     "/tmp/file.json",
+	}
+
+	applyServerConfig(serverConfig, map[string]bool{"a": true})
+
+	if flagRunAddr != "flag:8080" {
+		t.Errorf("flagRunAddr = %s; want flag value", flagRunAddr)
+	}
+	if flagLogLevel != "debug" {
+		t.Errorf("flagLogLevel = %s; want env value", flagLogLevel)
+	}
+	if flagFileStoragePath != "/tmp/file.json" {
+		t.Errorf("flagFileStoragePath = %s; want file value", flagFileStoragePath)
+	}
+	if flagStoreInterval != 30 {
+		t.Errorf("flagStoreInterval = %d; want 30", flagStoreInterval)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"alerting-service/internal/alerting"
+	"alerting-service/internal/auth"
 	"alerting-service/internal/compressor"
 	"alerting-service/internal/crypto"
 	"alerting-service/internal/db"
@@ -11,9 +13,11 @@ import (
 	"alerting-service/internal/repository"
 	"alerting-service/internal/server"
 	"alerting-service/internal/signature"
+	"alerting-service/internal/subnet"
 	"alerting-service/internal/usecases"
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -27,6 +31,10 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+// sampleCleanupInterval is how often samples older than the retention are
+// deleted.
+const sampleCleanupInterval = 10 * time.Minute
+
 var (
 	buildVersion string
 	buildDate    string
@@ -60,7 +68,15 @@ func main() {
 	metricsHandler := handlers.NewMetricHandler(metricUsecase)
 	obsHandler := observability.NewObsHandler(dbConn)
 
-	server := server.NewServer(flagRunAddr)
+	var tlsConfig *tls.Config
+	if flagTLSCert != "" || flagTLSKey != "" || flagTLSClientCA != "" {
+		tlsConfig, err = crypto.ServerTLSConfig(flagTLSCert, flagTLSKey, flagTLSClientCA)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	server := server.NewServer(flagRunAddr, tlsConfig)
 
 	var privateKey *rsa.PrivateKey
 
@@ -76,37 +92,75 @@ func main() {
 		panic(err)
 	}
 
-	r.Use(crypto.DecryptionMiddleware(privateKey))
+	trustedSubnet, err := subnet.Parse(flagTrustedSubnet)
+	if err != nil {
+		panic(err)
+	}
+	trusted := subnet.NewTrusted(trustedSubnet)
+
+	tokenStore, fileTokens, err := newTokenStore(dbConn)
+	if err != nil {
+		panic(err)
+	}
+
+	privateKeys := crypto.NewPrivateKeyHolder(privateKey)
+	r.Use(crypto.DecryptionMiddleware(privateKeys))
 	r.Use(logger.RequestLogger)
 	r.Use(logger.ResponseLogger)
 	r.Use(compressor.GzipMiddleware)
 
+	if flagMaxClockSkew <= 0 {
+		panic(ErrInvalidClockSkew)
+	}
+	if flagSampleRetention <= 0 {
+		panic(ErrInvalidRetention)
+	}
 	signature.SetServerHashKey(flagHashKey)
+	signature.SetMaxClockSkew(flagMaxClockSkew)
 	r.Use(signature.HashMiddleware)
 
 	r.Route("/update", func(r chi.Router) {
+		r.Use(subnet.Middleware(trusted))
+		r.Use(auth.Middleware(tokenStore, auth.ScopeWrite, auth.JSONMetrics))
 		r.Post("/", metricsHandler.UpdateMetric)
 	})
 
 	r.Route("/updates", func(r chi.Router) {
+		r.Use(subnet.Middleware(trusted))
+		r.Use(auth.Middleware(tokenStore, auth.ScopeWrite, auth.JSONMetrics))
 		r.Post("/", metricsHandler.UpdateMetrics)
 	})
 
 	r.Route("/value", func(r chi.Router) {
+		r.Use(auth.Middleware(tokenStore, auth.ScopeRead, auth.JSONMetrics))
 		r.Post("/", metricsHandler.GetMetric)
 	})
 
 	r.Route("/update/{metricType}/{metricName}/{metricValue}", func(r chi.Router) {
+		r.Use(subnet.Middleware(trusted))
+		r.Use(auth.Middleware(tokenStore, auth.ScopeWrite, auth.URLParam("metricName")))
 		r.Post("/", metricsHandler.UpdateURLMetric)
 	})
 
 	r.Route("/value/{metricType}/{metricName}", func(r chi.Router) {
+		r.Use(auth.Middleware(tokenStore, auth.ScopeRead, auth.URLParam("metricName")))
 		r.Get("/", metricsHandler.GetURLMetric)
 	})
 
+	r.Route("/query_range", func(r chi.Router) {
+		r.Use(auth.Middleware(tokenStore, auth.ScopeRead, auth.QueryParam("id")))
+		r.Get("/", metricsHandler.QueryRange)
+	})
+
+	r.Route("/metrics", func(r chi.Router) {
+		r.Use(auth.Middleware(tokenStore, auth.ScopeRead, nil))
+		r.Get("/", metricsHandler.GetPrometheusMetrics)
+	})
+
 	r.Get("/ping", obsHandler.HealthCheckDB)
 
 	r.Route("/", func(r chi.Router) {
+		r.Use(auth.Middleware(tokenStore, auth.ScopeRead, nil))
 		r.Get("/", metricsHandler.GetAllMetrics)
 	})
 
@@ -121,13 +175,64 @@ func main() {
 	}
 	storageRepository.SetMetrics(allMetrics)
 
+	alertCtx, stopAlerts := context.WithCancel(context.Background())
+	defer stopAlerts()
+
+	reloader := &reloader{privateKeys: privateKeys, trusted: trusted, tokens: fileTokens, storeInterval: make(chan time.Duration, 1)}
+
+	if flagAlertRules != "" {
+		alertConfig, err := alerting.LoadConfig(flagAlertRules)
+		if err != nil {
+			panic(err)
+		}
+
+		var notifiers []alerting.Notifier
+		if len(alertConfig.Notifications.Webhooks) > 0 {
+			var deliveryLog *alerting.DeliveryLog
+			if alertConfig.Notifications.DeliveryLog != "" {
+				deliveryLog, err = alerting.NewDeliveryLog(alertConfig.Notifications.DeliveryLog)
+				if err != nil {
+					panic(err)
+				}
+				defer deliveryLog.Close()
+			}
+
+			webhookClient := &http.Client{Timeout: 10 * time.Second}
+			webhookNotifier := alerting.NewWebhookNotifier(webhookClient, alertConfig.Notifications, deliveryLog)
+			go webhookNotifier.Run(alertCtx)
+			notifiers = append(notifiers, webhookNotifier)
+		}
+
+		alertEngine := alerting.NewEngine(storageRepository, alertConfig.Rules, notifiers...)
+		go alertEngine.Run(alertCtx, alertConfig.EvaluationInterval.Duration())
+
+		reloader.alertEngine = alertEngine
+		reloader.alertConfig = alertConfig
+	}
+
 	idleConnsClosed := make(chan struct{})
 	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-sighup:
+				logger.Log.Info("Received reload signal")
+				reloader.reload()
+			case <-idleConnsClosed:
+				return
+			}
+		}
+	}()
+
 	go func() {
 		<-sigint
 		logger.Log.Info("Received shutdown signal")
+		stopAlerts()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -158,14 +263,19 @@ func main() {
 		}
 	}()
 
+	storeInterval := time.Duration(flagStoreInterval) * time.Second
+	fileStoragePath := flagFileStoragePath
+
 	go func() {
-		ticker := time.NewTicker(time.Duration(flagStoreInterval) * time.Second)
+		ticker := time.NewTicker(storeInterval)
 		defer ticker.Stop()
 
 		for {
 			select {
+			case interval := <-reloader.storeInterval:
+				ticker.Reset(interval)
 			case <-ticker.C:
-				if err := os.Truncate(flagFileStoragePath, 0); err != nil {
+				if err := os.Truncate(fileStoragePath, 0); err != nil {
 					logger.Log.Error("Error truncating backup file", zap.Error(err))
 					continue
 				}
@@ -185,6 +295,27 @@ func main() {
 		}
 	}()
 
+	sampleRetention := flagSampleRetention
+
+	go func() {
+		ticker := time.NewTicker(sampleCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				deleted, err := storageRepository.DeleteSamples(time.Now().Add(-sampleRetention))
+				if err != nil {
+					logger.Log.Error("Error deleting old metric samples", zap.Error(err))
+					continue
+				}
+				logger.Log.Debug("Deleted old metric samples", zap.Int64("samples", deleted))
+			case <-idleConnsClosed:
+				return
+			}
+		}
+	}()
+
 	<-idleConnsClosed
 	logger.Log.Info("Server stopped gracefully")
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"alerting-service/internal/alerting"
+	"alerting-service/internal/auth"
+	"alerting-service/internal/crypto"
+	"alerting-service/internal/logger"
+	"alerting-service/internal/signature"
+	"alerting-service/internal/subnet"
+	"errors"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	ErrInvalidStoreInterval = errors.New("store interval must be positive")
+	ErrInvalidClockSkew     = errors.New("max clock skew must be positive")
+	ErrInvalidRetention     = errors.New("sample retention must be positive")
+)
+
+// reloader applies a re-read configuration to the running server.
+type reloader struct {
+	privateKeys   *crypto.PrivateKeyHolder
+	trusted       *subnet.Trusted
+	tokens        *auth.FileStore  // nil unless tokens are read from a file
+	alertEngine   *alerting.Engine // nil when alert rules are disabled
+	alertConfig   *alerting.Config
+	storeInterval chan time.Duration
+}
+
+// reload re-reads the config file on SIGHUP. The new configuration is
+// validated as a whole before anything is applied; options that cannot be
+// changed while the server runs are reported and keep their current values.
+func (r *reloader) reload() {
+	previous := currentFlags()
+
+	if err := reloadFlags(); err != nil {
+		logger.Log.Error("Failed to reload config, keeping the current one", zap.Error(err))
+		return
+	}
+
+	updated := currentFlags()
+	if err := r.apply(previous, updated); err != nil {
+		previous.restore()
+		logger.Log.Error("Invalid config, keeping the current one", zap.Error(err))
+		return
+	}
+}
+
+func (r *reloader) apply(previous, updated serverFlags) error {
+	level, err := zapcore.ParseLevel(updated.LogLevel)
+	if err != nil {
+		return err
+	}
+	if updated.StoreInterval <= 0 {
+		return ErrInvalidStoreInterval
+	}
+	if updated.MaxClockSkew <= 0 {
+		return ErrInvalidClockSkew
+	}
+	if updated.SampleRetention <= 0 {
+		return ErrInvalidRetention
+	}
+
+	privateKey, err := crypto.LoadPrivateKey(updated.CryptoKey)
+	if err != nil {
+		return err
+	}
+
+	trustedSubnet, err := subnet.Parse(updated.TrustedSubnet)
+	if err != nil {
+		return err
+	}
+
+	var tokens []auth.Token
+	reloadTokens := updated.TokensFile != "" && r.tokens != nil
+	if reloadTokens {
+		tokens, err = auth.LoadFile(updated.TokensFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var alertConfig *alerting.Config
+	if updated.AlertRules != "" && r.alertEngine != nil {
+		alertConfig, err = alerting.LoadConfig(updated.AlertRules)
+		if err != nil {
+			return err
+		}
+	}
+
+	restart := restartRequired(previous, updated)
+	if (r.alertEngine == nil) != (updated.AlertRules == "") {
+		restart = append(restart, "alert_rules")
+		updated.AlertRules = previous.AlertRules
+	}
+	if alertConfig != nil && !reflect.DeepEqual(alertConfig.Notifications, r.alertConfig.Notifications) {
+		restart = append(restart, "notifications")
+		alertConfig.Notifications = r.alertConfig.Notifications
+	}
+	if len(restart) > 0 {
+		logger.Log.Warn("Config changes require a restart and were not applied", zap.Strings("fields", restart))
+		updated.RunAddr = previous.RunAddr
+		updated.FileStoragePath = previous.FileStoragePath
+		updated.Restore = previous.Restore
+		updated.DBConnectionString = previous.DBConnectionString
+		updated.TLSCert = previous.TLSCert
+		updated.TLSKey = previous.TLSKey
+		updated.TLSClientCA = previous.TLSClientCA
+		updated.TokensDB = previous.TokensDB
+		updated.SampleRetention = previous.SampleRetention
+		if (previous.TokensFile == "") != (updated.TokensFile == "") {
+			updated.TokensFile = previous.TokensFile
+		}
+		updated.restore()
+	}
+
+	signature.SetServerHashKey(updated.HashKey)
+	signature.SetMaxClockSkew(updated.MaxClockSkew)
+	r.privateKeys.Store(privateKey)
+	r.trusted.Store(trustedSubnet)
+	if reloadTokens {
+		r.tokens.Store(tokens)
+	}
+
+	select {
+	case <-r.storeInterval:
+	default:
+	}
+	r.storeInterval <- time.Duration(updated.StoreInterval) * time.Second
+
+	if alertConfig != nil {
+		r.alertEngine.Update(alertConfig.Rules, alertConfig.EvaluationInterval.Duration())
+		r.alertConfig = alertConfig
+	}
+
+	logger.Log.Info("Config reloaded",
+		zap.String("log_level", level.String()),
+		zap.Int("store_interval", updated.StoreInterval),
+		zap.Bool("hash_key", updated.HashKey != ""),
+		zap.Duration("max_clock_skew", updated.MaxClockSkew),
+		zap.Bool("crypto_key", privateKey != nil),
+		zap.String("trusted_subnet", updated.TrustedSubnet),
+		zap.Int("tokens", len(tokens)))
+
+	logger.SetLevel(level)
+	return nil
+}
+
+// restartRequired returns the config fields that changed but are only read
+// on start.
+func restartRequired(previous, updated serverFlags) []string {
+	var fields []string
+	if previous.RunAddr != updated.RunAddr {
+		fields = append(fields, "address")
+	}
+	if previous.FileStoragePath != updated.FileStoragePath {
+		fields = append(fields, "store_file")
+	}
+	if previous.Restore != updated.Restore {
+		fields = append(fields, "restore")
+	}
+	if previous.DBConnectionString != updated.DBConnectionString {
+		fields = append(fields, "database_dsn")
+	}
+	if previous.TLSCert != updated.TLSCert || previous.TLSKey != updated.TLSKey || previous.TLSClientCA != updated.TLSClientCA {
+		fields = append(fields, "tls")
+	}
+	if previous.TokensDB != updated.TokensDB || (previous.TokensFile == "") != (updated.TokensFile == "") {
+		fields = append(fields, "tokens")
+	}
+	if previous.SampleRetention != updated.SampleRetention {
+		fields = append(fields, "sample_retention")
+	}
+
+	return fields
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alerting-service/internal/auth"
+	"alerting-service/internal/crypto"
+	"alerting-service/internal/subnet"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "server.json")
+	content := `{"address": "other:9090", "log_level": "warn", "store_interval": "30s", "hash_key": "new-key", "trusted_subnet": "10.0.0.0/8"}`
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile = filename
+	flagsSet = map[string]bool{}
+	flagRunAddr = "localhost:8080"
+	flagLogLevel = "info"
+	flagStoreInterval = 300
+	flagMaxClockSkew = time.Minute
+	flagSampleRetention = time.Hour
+	flagHashKey = "old-key"
+	defer func() { configFile = "" }()
+
+	r := &reloader{privateKeys: crypto.NewPrivateKeyHolder(nil), trusted: subnet.NewTrusted(nil), storeInterval: make(chan time.Duration, 1)}
+	r.reload()
+
+	if flagHashKey != "new-key" || flagLogLevel != "warn" || flagStoreInterval != 30 {
+		t.Errorf("safe options not applied: key %q, level %q, interval %d", flagHashKey, flagLogLevel, flagStoreInterval)
+	}
+	if trusted := r.trusted.Load(); trusted == nil || trusted.String() != "10.0.0.0/8" {
+		t.Errorf("trusted subnet not applied: %v", trusted)
+	}
+	if flagRunAddr != "localhost:8080" {
+		t.Errorf("flagRunAddr = %s; want the address to require a restart", flagRunAddr)
+	}
+	select {
+	case interval := <-r.storeInterval:
+		if interval != 30*time.Second {
+			t.Errorf("store interval = %s; want 30s", interval)
+		}
+	default:
+		t.Error("store interval not updated")
+	}
+}
+
+func TestReloader_ReloadInvalid(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "server.json")
+	if err := os.WriteFile(filename, []byte(`{"log_level": "loud", "hash_key": "new-key"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile = filename
+	flagsSet = map[string]bool{}
+	flagLogLevel = "info"
+	flagStoreInterval = 300
+	flagMaxClockSkew = time.Minute
+	flagSampleRetention = time.Hour
+	flagHashKey = "old-key"
+	defer func() { configFile = "" }()
+
+	r := &reloader{privateKeys: crypto.NewPrivateKeyHolder(nil), trusted: subnet.NewTrusted(nil), storeInterval: make(chan time.Duration, 1)}
+	r.reload()
+
+	if flagHashKey != "old-key" || flagLogLevel != "info" {
+		t.Errorf("invalid config partially applied: key %q, level %q", flagHashKey, flagLogLevel)
+	}
+	if len(r.storeInterval) != 0 {
+		t.Error("store interval updated from an invalid config")
+	}
+}
+
+func TestReloader_ReloadTokens(t *testing.T) {
+	dir := t.TempDir()
+	tokensFile := filepath.Join(dir, "tokens.json")
+	if err := os.WriteFile(tokensFile, []byte(`{"tokens": []}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "server.json")
+	if err := os.WriteFile(filename, []byte(`{"tokens_file": "`+tokensFile+`"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile = filename
+	flagsSet = map[string]bool{}
+	flagLogLevel = "info"
+	flagStoreInterval = 300
+	flagMaxClockSkew = time.Minute
+	flagSampleRetention = time.Hour
+	flagTokensFile = tokensFile
+	defer func() { configFile, flagTokensFile = "", "" }()
+
+	tokens := auth.NewFileStore([]auth.Token{{Name: "web-1", Hash: auth.HashToken("secret"), Scope: auth.ScopeWrite}})
+	r := &reloader{privateKeys: crypto.NewPrivateKeyHolder(nil), trusted: subnet.NewTrusted(nil), tokens: tokens, storeInterval: make(chan time.Duration, 1)}
+	r.reload()
+
+	if _, err := tokens.Lookup(context.Background(), "secret"); !errors.Is(err, auth.ErrUnknownToken) {
+		t.Errorf("expected token removed from the file to be revoked, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"alerting-service/internal/auth"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrTokenSources     = errors.New("tokens-file and tokens-db cannot be used together")
+	ErrTokensNoDatabase = errors.New("tokens-db requires a database connection")
+)
+
+// newTokenStore returns the store of API tokens, or nil when token
+// authentication is disabled. The file store is also returned so that it
+// can be reloaded.
+func newTokenStore(dbConn *sql.DB) (auth.Store, *auth.FileStore, error) {
+	switch {
+	case flagTokensFile != "" && flagTokensDB:
+		return nil, nil, ErrTokenSources
+	case flagTokensFile != "":
+		tokens, err := auth.LoadFile(flagTokensFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileStore := auth.NewFileStore(tokens)
+		return fileStore, fileStore, nil
+	case flagTokensDB:
+		if dbConn == nil {
+			return nil, nil, ErrTokensNoDatabase
+		}
+		return auth.NewDBStore(dbConn), nil, nil
+	default:
+		return nil, nil, nil
+	}
+}
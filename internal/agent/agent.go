@@ -6,41 +6,134 @@ import (
 	"alerting-service/internal/logger"
 	"alerting-service/internal/models"
 	sign "alerting-service/internal/signature"
+	"alerting-service/internal/subnet"
+	"alerting-service/internal/utils"
 	"context"
 	"crypto/rsa"
+	"errors"
+	"io"
+	"os"
 	"sync"
+	"sync/atomic"
 
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"runtime"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
-var pollCount int
+var (
+	ErrServerUnreachable        = errors.New("server is unreachable")
+	ErrUnexpectedStatus         = errors.New("unexpected response status")
+	ErrInvalidResponseSignature = errors.New("response signature does not match")
+)
+
+// maxResponseSize limits the response body read to verify its signature.
+const maxResponseSize = 1 << 20
 
-type stats map[string]float64
+// shutdownTimeout limits how long batches are sent after the agent is asked
+// to stop; batches that are not sent by then go to the outbox.
+const shutdownTimeout = 10 * time.Second
 
 type sentMetricWorker struct {
 	client    *http.Client
 	conf      *config.Config
 	publicKey *rsa.PublicKey
+	outbox    *Outbox
+	retry     RetryPolicy
+	counters  *counterTracker
+	telemetry *Telemetry
+	realIP    string
+}
+
+// RuntimeAgent runs the agent with the built-in collectors until the
+// context is cancelled. The configuration is read again on every signal
+// received from reload. It fails only if the initial configuration is invalid.
+func RuntimeAgent(ctx context.Context, client *http.Client, reload <-chan os.Signal) error {
+	conf, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	level, err := zapcore.ParseLevel(conf.LogLevel)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(level)
+
+	if conf.TLSEnabled() {
+		client, err = tlsClient(client, conf)
+		if err != nil {
+			return err
+		}
+	}
+
+	configs := make(chan *config.Config)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload:
+				logger.Log.Info("Received reload signal")
+				conf, err := config.ReloadConfig()
+				if err != nil {
+					logger.Log.Error("Failed to reload config, keeping the current one", zap.Error(err))
+					continue
+				}
+				select {
+				case configs <- conf:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	Run(ctx, client, conf, DefaultRegistry(conf), configs)
+	return nil
 }
 
-func RuntimeAgent(ctx context.Context, client *http.Client) {
-	conf := config.GetConfig()
-	memStat := &runtime.MemStats{}
-	runtime.ReadMemStats(memStat)
-	stats := make(map[string]float64)
-	metricsChan := make(chan models.Metrics, 30)
+// tlsClient returns a copy of client whose transport verifies the server
+// and presents the client certificate as configured.
+func tlsClient(client *http.Client, conf *config.Config) (*http.Client, error) {
+	tlsConfig, err := crypto.ClientTLSConfig(conf.TLSCA, conf.TLSCert, conf.TLSKey, conf.TLSServerName)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	tlsClient := *client
+	tlsClient.Transport = transport
+	return &tlsClient, nil
+}
+
+// Run runs the enabled collectors of the registry and reports their metrics
+// every report interval until the context is cancelled. Configurations
+// received from reloads are applied while the agent runs.
+func Run(ctx context.Context, client *http.Client, conf *config.Config, registry *Registry, reloads <-chan *config.Config) {
+	batchesChan := make(chan []models.Metrics, conf.RateLimit)
 	resultsChan := make(chan error, conf.RateLimit)
 
+	collectors, err := registry.Build(conf.Collectors, conf.EnabledCollectors)
+	if err != nil {
+		logger.Log.Error("Failed to configure collectors", zap.Error(err))
+		return
+	}
+
 	var publicKey *rsa.PublicKey
-	var err error
 	if conf.CryptoKey != "" {
 		publicKey, err = crypto.LoadPublicKey(conf.CryptoKey)
 		if err != nil {
@@ -48,37 +141,107 @@ func RuntimeAgent(ctx context.Context, client *http.Client) {
 		}
 	}
 
+	var outbox *Outbox
+	if conf.OutboxDir != "" {
+		outbox, err = OpenOutbox(conf.OutboxDir, conf.OutboxMaxSize, conf.OutboxMaxAge)
+		if err != nil {
+			logger.Log.Error("Failed to open outbox, unsent metrics will be dropped", zap.Error(err))
+			outbox = nil
+		} else if queued := outbox.Len(); queued > 0 {
+			logger.Log.Info("Found unsent batches in outbox", zap.Int("batches", queued))
+		}
+	}
+
+	labels := hostLabels(conf.RunAddr, conf.Labels)
+	logger.Log.Info("Metrics are labeled", zap.Any("labels", labels))
+
+	outboxLength := func() int { return 0 }
+	if outbox != nil {
+		outboxLength = outbox.Len
+	}
+	telemetry := NewTelemetry(func() int { return len(batchesChan) }, outboxLength)
+	telemetry.SetStaleAfter(HealthStaleReports * time.Duration(conf.ReportInterval) * time.Second)
+
+	workers := &atomic.Pointer[sentMetricWorker]{}
+	workers.Store(&sentMetricWorker{
+		client:    client,
+		conf:      conf,
+		publicKey: publicKey,
+		outbox:    outbox,
+		retry:     NewRetryPolicy(conf.RetryAttempts, conf.RetryMinDelay, conf.RetryMaxDelay),
+		counters:  newCounterTracker(),
+		telemetry: telemetry,
+		realIP:    outboundAddr(conf.RunAddr),
+	})
+
 	var wg sync.WaitGroup
 
+	if conf.MetricsAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			telemetry.Serve(ctx, conf.MetricsAddr)
+		}()
+	}
+
+	sendCtx, cancelSend := sendContext(ctx, shutdownTimeout)
+	defer cancelSend()
+
 	for w := 1; w <= conf.RateLimit; w++ {
-		worker := sentMetricWorker{client: client, conf: conf, publicKey: publicKey}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			worker.sendMetric(ctx, metricsChan, resultsChan)
+			sendMetric(sendCtx, workers, batchesChan, resultsChan)
 		}()
 	}
 
-	reportTicker := time.NewTicker(time.Duration(conf.ReportInterval) * time.Second)
-	defer reportTicker.Stop()
+	resultsDone := make(chan struct{})
+	go func() {
+		defer close(resultsDone)
+		for err := range resultsChan {
+			logSendResult(err)
+		}
+	}()
+
+	if outbox != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replayOutbox(ctx, workers, outbox, time.Duration(conf.ReportInterval)*time.Second)
+		}()
+	}
 
-	pollTicker := time.NewTicker(time.Duration(conf.PollInterval) * time.Second)
-	defer pollTicker.Stop()
+	store := newCollectorStore()
+	r := &runner{
+		conf:         conf,
+		registry:     registry,
+		workers:      workers,
+		store:        store,
+		telemetry:    telemetry,
+		labels:       labels,
+		collectors:   startCollectors(ctx, collectors, time.Duration(conf.PollInterval)*time.Second, store, telemetry),
+		reportTicker: time.NewTicker(time.Duration(conf.ReportInterval) * time.Second),
+	}
 
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
+		defer r.reportTicker.Stop()
+
 		for {
 			select {
 			case <-ctx.Done():
 				logger.Log.Info("Shutdown signal received, sending remaining metrics...")
-				sendMetrics(stats, metricsChan)
-				close(metricsChan)
+				r.report(batchesChan)
+				close(batchesChan)
+				r.collectors.stop()
 				return
-			case <-pollTicker.C:
-				pollCount++
-				runtime.ReadMemStats(memStat)
-				getMemStatData(memStat, stats)
-			case <-reportTicker.C:
-				sendMetrics(stats, metricsChan)
+			case <-r.reportTicker.C:
+				r.report(batchesChan)
+			case updated := <-reloads:
+				if err := r.reload(ctx, updated); err != nil {
+					logger.Log.Error("Invalid config, keeping the current one", zap.Error(err))
+				}
 			}
 		}
 	}()
@@ -88,198 +251,292 @@ func RuntimeAgent(ctx context.Context, client *http.Client) {
 	wg.Wait()
 
 	close(resultsChan)
-
-	for err := range resultsChan {
-		if err != nil {
-			logger.Log.Error("Error sending metric during shutdown", zap.Error(err))
-		}
-	}
+	<-resultsDone
 
 	logger.Log.Info("Agent stopped gracefully")
 }
 
-func (w *sentMetricWorker) sendMetric(ctx context.Context, metricsChan <-chan models.Metrics, resultsChan chan<- error) {
-	for {
+// sendContext returns the context batches are sent with. It outlives ctx,
+// so the batches reported on shutdown are still sent, but is cancelled
+// timeout after ctx is done, so a server that does not respond cannot hold
+// up the shutdown.
+func sendContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	sendCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
 		select {
-		case metric, ok := <-metricsChan:
-			if !ok {
-				return
-			}
-			var err error
-			select {
-			case <-ctx.Done():
-				resultsChan <- nil
-				return
-			default:
-				switch metric.MType {
-				case models.GaugeMetric:
-					err = w.sendGaugeMetric(metric)
-				case models.CounterMetric:
-					err = w.sendCounterMetric(metric)
-				}
-				resultsChan <- err
-			}
-		case <-ctx.Done():
-			return
+		case <-time.After(timeout):
+			cancel()
+		case <-sendCtx.Done():
 		}
+	})
+
+	return sendCtx, func() {
+		stop()
+		cancel()
 	}
 }
 
-func getMemStatData(memStat *runtime.MemStats, stats stats) {
-	stats["Alloc"] = float64(memStat.Alloc)
-	stats["BuckHashSys"] = float64(memStat.BuckHashSys)
-	stats["Frees"] = float64(memStat.Frees)
-	stats["GCCPUFraction"] = float64(memStat.GCCPUFraction)
-	stats["GCSys"] = float64(memStat.GCSys)
-	stats["HeapAlloc"] = float64(memStat.HeapAlloc)
-	stats["HeapIdle"] = float64(memStat.HeapIdle)
-	stats["HeapInuse"] = float64(memStat.HeapInuse)
-	stats["HeapObjects"] = float64(memStat.HeapObjects)
-	stats["HeapReleased"] = float64(memStat.HeapReleased)
-	stats["HeapSys"] = float64(memStat.HeapSys)
-	stats["LastGC"] = float64(memStat.LastGC)
-	stats["Lookups"] = float64(memStat.Lookups)
-	stats["MCacheInuse"] = float64(memStat.MCacheInuse)
-	stats["MCacheSys"] = float64(memStat.MCacheSys)
-	stats["MSpanInuse"] = float64(memStat.MSpanInuse)
-	stats["MSpanSys"] = float64(memStat.MSpanSys)
-	stats["Mallocs"] = float64(memStat.Mallocs)
-	stats["NextGC"] = float64(memStat.NextGC)
-	stats["NumForcedGC"] = float64(memStat.NumForcedGC)
-	stats["NumGC"] = float64(memStat.NumGC)
-	stats["OtherSys"] = float64(memStat.OtherSys)
-	stats["PauseTotalNs"] = float64(memStat.PauseTotalNs)
-	stats["StackInuse"] = float64(memStat.StackInuse)
-	stats["StackSys"] = float64(memStat.StackSys)
-	stats["Sys"] = float64(memStat.Sys)
-	stats["TotalAlloc"] = float64(memStat.TotalAlloc)
+// sendMetric sends batches until the channel is closed, so the batches
+// queued on shutdown are still delivered. Every batch is sent with the
+// current worker settings. Counter deltas are committed only once the batch
+// is delivered or queued in the outbox; a batch the server accepted with an
+// invalid response signature counts as delivered, since resending it would
+// count its deltas twice.
+func sendMetric(ctx context.Context, workers *atomic.Pointer[sentMetricWorker], batchesChan <-chan []models.Metrics, resultsChan chan<- error) {
+	for batch := range batchesChan {
+		w := workers.Load()
+		err := w.deliver(ctx, batch)
+		if w.counters != nil {
+			if err == nil || isDelivered(err) {
+				w.counters.Ack(batch)
+			} else {
+				w.counters.Fail(batch)
+			}
+		}
+		resultsChan <- err
+	}
 }
 
-func sendMetrics(stats stats, metricChan chan models.Metrics) {
-	for key, value := range stats {
-		metric := models.Metrics{
-			ID:    key,
-			MType: "gauge",
-			Value: &value,
-		}
-		metricChan <- metric
+// logSendResult logs a failed delivery; rejected batches point to a
+// misconfiguration such as a wrong key, so they are logged with the status.
+func logSendResult(err error) {
+	if err == nil {
+		return
 	}
 
-	pollCount := int64(pollCount)
-	metric := models.Metrics{
-		ID:    "PollCount",
-		MType: "counter",
-		Delta: &pollCount,
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) {
+		logger.Log.Error("Error sending metrics", zap.Error(err))
+		return
+	}
+
+	fields := []zap.Field{
+		zap.Int("metrics", sendErr.Metrics),
+		zap.Int("attempts", sendErr.Attempts),
+		zap.Bool("retryable", sendErr.Retryable()),
+		zap.Error(sendErr.Err),
+	}
+	if sendErr.StatusCode != 0 {
+		fields = append(fields, zap.Int("status", sendErr.StatusCode), zap.String("response", sendErr.Body))
+	}
+
+	switch {
+	case sendErr.Delivered():
+		logger.Log.Error("Metrics accepted but the server response could not be verified, check the hash key", fields...)
+	case sendErr.Retryable():
+		logger.Log.Error("Failed to send metrics", fields...)
+	default:
+		logger.Log.Error("Server rejected metrics, check the agent configuration", fields...)
 	}
-	metricChan <- metric
 }
 
-func (w *sentMetricWorker) sendGaugeMetric(metric models.Metrics) error {
-	body, err := json.Marshal(metric)
-	if err != nil {
-		logger.Log.Error("marshal error", zap.Error(err))
-		return err
+// deliver sends the batch, or queues it in the outbox when the server is
+// unreachable. While the outbox is not empty new batches are queued behind
+// the old ones, so the server receives them in order.
+func (w *sentMetricWorker) deliver(ctx context.Context, batch []models.Metrics) error {
+	if w.outbox == nil {
+		return w.sendBatch(ctx, batch)
 	}
 
-	finalBody := body
-	if w.publicKey != nil {
-		finalBody, err = crypto.EncryptData(body, w.publicKey)
-		if err != nil {
-			logger.Log.Error("encryption error", zap.Error(err))
+	if w.outbox.Len() == 0 {
+		err := w.sendBatch(ctx, batch)
+		if !isRetryable(err) {
 			return err
 		}
+		logger.Log.Warn("Failed to send batch, queueing it in outbox", zap.Error(err))
 	}
 
-	var buf bytes.Buffer
-	g := gzip.NewWriter(&buf)
-	_, err = g.Write(finalBody)
-	if err != nil {
-		logger.Log.Error("gzip write error", zap.Error(err))
-		g.Close()
-		return err
-	}
-	g.Close()
+	return w.outbox.Append(batch)
+}
 
-	url := fmt.Sprintf("http://%s/update", w.conf.RunAddr)
-	req, err := http.NewRequest("POST", url, &buf)
-	if err != nil {
-		logger.Log.Error("request error", zap.Error(err))
-		return err
-	}
+// replayOutbox replays the outbox on start and then every interval until
+// the context is cancelled. Batches are sent once, with the current worker
+// settings. Batches left on shutdown are replayed on the next start.
+func replayOutbox(ctx context.Context, workers *atomic.Pointer[sentMetricWorker], outbox *Outbox, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	req.Header.Add("Accept-Encoding", "gzip")
-	req.Header.Set("Content-Type", "application/json")
+	for {
+		replayer := *workers.Load()
+		replayer.outbox = nil
+		replayer.retry = RetryPolicy{}
+
+		err := outbox.Replay(func(batch []models.Metrics) error {
+			err := replayer.sendBatch(ctx, batch)
+			if err != nil && !isRetryable(err) {
+				logSendResult(err)
+				if !isDelivered(err) {
+					logger.Log.Error("Dropping outbox batch rejected by the server", zap.Int("metrics", len(batch)))
+				}
+				return nil
+			}
+			return err
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Log.Warn("Failed to replay outbox", zap.Int("batches", outbox.Len()), zap.Error(err))
+		}
 
-	if w.publicKey != nil {
-		req.Header.Set("Content-Encryption", "RSA")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	if w.conf.HashKey != "" {
-		signature := sign.GetHash(body, []byte(w.conf.HashKey))
-		req.Header.Set(sign.HashSHA256, signature)
+// sendMetrics splits the metrics into batches of at most batchSize metrics
+// and queues them for the workers.
+func sendMetrics(metrics []models.Metrics, batchSize int, batchesChan chan<- []models.Metrics) {
+	if batchSize <= 0 {
+		batchSize = len(metrics)
 	}
 
-	response, err := w.client.Do(req)
+	for start := 0; start < len(metrics); start += batchSize {
+		end := min(start+batchSize, len(metrics))
+		batchesChan <- metrics[start:end]
+	}
+}
+
+// sendBatch posts the batch to /updates as a single JSON array, retrying
+// according to the worker retry policy.
+func (w *sentMetricWorker) sendBatch(ctx context.Context, batch []models.Metrics) error {
+	body, payload, err := w.encodeBatch(batch)
 	if err != nil {
-		logger.Log.Error("http send error", zap.Error(err))
 		return err
 	}
-	defer response.Body.Close()
 
-	return nil
+	return w.retry.Do(ctx, func() error {
+		return w.post(ctx, len(batch), body, payload)
+	})
 }
 
-func (w *sentMetricWorker) sendCounterMetric(metric models.Metrics) error {
-	body, err := json.Marshal(metric)
+// encodeBatch returns the JSON of the batch and the request payload: the
+// JSON gzipped and then optionally encrypted.
+func (w *sentMetricWorker) encodeBatch(batch []models.Metrics) ([]byte, []byte, error) {
+	body, err := json.Marshal(batch)
 	if err != nil {
 		logger.Log.Error("marshal error", zap.Error(err))
-		return err
+		return nil, nil, err
 	}
 
-	finalBody := body
+	var buf bytes.Buffer
+	g := gzip.NewWriter(&buf)
+	_, err = g.Write(body)
+	if err != nil {
+		logger.Log.Error("gzip write error", zap.Error(err))
+		g.Close()
+		return nil, nil, err
+	}
+	if err = g.Close(); err != nil {
+		logger.Log.Error("gzip close error", zap.Error(err))
+		return nil, nil, err
+	}
+
+	payload := buf.Bytes()
 	if w.publicKey != nil {
-		finalBody, err = crypto.EncryptData(body, w.publicKey)
+		payload, err = crypto.EncryptEnvelope(payload, w.publicKey)
 		if err != nil {
 			logger.Log.Error("encryption error", zap.Error(err))
-			return err
+			return nil, nil, err
 		}
 	}
 
-	var buf bytes.Buffer
-	g := gzip.NewWriter(&buf)
-	_, err = g.Write(finalBody)
+	return body, payload, nil
+}
+
+// readResponseBody returns the response body, decompressed if the server
+// gzipped it.
+func readResponseBody(response *http.Response) ([]byte, error) {
+	var body io.Reader = io.LimitReader(response.Body, maxResponseSize)
+	if strings.Contains(response.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	return io.ReadAll(body)
+}
+
+// outboundAddr returns the local address used to reach the server, sent in
+// the X-Real-IP header so the server can check it against its trusted subnet.
+func outboundAddr(serverAddr string) string {
+	ip, err := utils.OutboundIP(serverAddr)
 	if err != nil {
-		logger.Log.Error("gzip write error", zap.Error(err))
-		g.Close()
-		return err
+		logger.Log.Warn("Failed to detect outbound IP, sending without X-Real-IP", zap.Error(err))
+		return ""
 	}
-	g.Close()
 
-	url := fmt.Sprintf("http://%s/update", w.conf.RunAddr)
-	req, err := http.NewRequest("POST", url, &buf)
+	return ip.String()
+}
+
+// post makes a single request; the signature covers the JSON body.
+func (w *sentMetricWorker) post(ctx context.Context, count int, body, payload []byte) (err error) {
+	scheme := "http"
+	if w.conf.TLSEnabled() {
+		scheme = "https"
+	}
+	url := fmt.Sprintf("%s://%s/updates/", scheme, w.conf.RunAddr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
 	if err != nil {
 		logger.Log.Error("request error", zap.Error(err))
 		return err
 	}
 
 	req.Header.Add("Accept-Encoding", "gzip")
+	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Content-Type", "application/json")
 
 	if w.publicKey != nil {
-		req.Header.Set("Content-Encryption", "RSA")
+		req.Header.Set(crypto.HeaderContentEncryption, crypto.EncryptionEnvelopeV1)
+	}
+
+	if w.realIP != "" {
+		req.Header.Set(subnet.RealIPHeader, w.realIP)
+	}
+
+	if w.conf.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.conf.Token)
 	}
 
 	if w.conf.HashKey != "" {
-		signature := sign.GetHash(body, []byte(w.conf.HashKey))
-		req.Header.Set(sign.HashSHA256, signature)
+		if err := sign.SignRequest(req, body, []byte(w.conf.HashKey)); err != nil {
+			return err
+		}
 	}
 
+	w.telemetry.SendAttempted(len(payload))
+	defer func() { w.telemetry.SendFinished(err) }()
+
 	response, err := w.client.Do(req)
 	if err != nil {
-		logger.Log.Error("http send error", zap.Error(err))
-		return err
+		logger.Log.Warn("http send error", zap.Error(err))
+		return &SendError{Metrics: count, Err: fmt.Errorf("%w: %w", ErrServerUnreachable, err)}
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(response.Body, 256))
+		logger.Log.Warn("server rejected metrics", zap.Int("status", response.StatusCode))
+		return &SendError{
+			StatusCode: response.StatusCode,
+			Body:       strings.TrimSpace(string(text)),
+			Metrics:    count,
+			Err:        fmt.Errorf("%w: %d", ErrUnexpectedStatus, response.StatusCode),
+		}
+	}
+
+	if w.conf.HashKey != "" {
+		body, err := readResponseBody(response)
+		if err != nil || !sign.VerifyResponse(response.Header, body, []byte(w.conf.HashKey)) {
+			logger.Log.Warn("server response signature does not match", zap.Int("status", response.StatusCode))
+			return &SendError{StatusCode: response.StatusCode, Metrics: count, Err: ErrInvalidResponseSignature}
+		}
+		return nil
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+
 	return nil
 }
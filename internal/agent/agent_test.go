@@ -1,8 +1,29 @@
 package agent
 
 import (
+	"alerting-service/internal/compressor"
+	"alerting-service/internal/config"
+	"alerting-service/internal/crypto"
+	"alerting-service/internal/models"
+	sign "alerting-service/internal/signature"
+	"alerting-service/internal/subnet"
+	"alerting-service/internal/utils"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_getMemStatData(t *testing.T) {
@@ -19,3 +40,261 @@ func Test_getMemStatData(t *testing.T) {
 		t.Errorf("expected at least 10 metrics, got %d", len(stats))
 	}
 }
+
+func Test_hostLabels(t *testing.T) {
+	labels := hostLabels("127.0.0.1:8080", map[string]string{"env": "prod", LabelHost: "custom"})
+
+	if _, ok := labels["pid"]; ok {
+		t.Errorf("expected no pid label, got %q", labels["pid"])
+	}
+	if labels[LabelInstance] != "127.0.0.1" {
+		t.Errorf("expected instance 127.0.0.1, got %q", labels[LabelInstance])
+	}
+	if labels[LabelHost] != "custom" {
+		t.Errorf("expected static host label to win, got %q", labels[LabelHost])
+	}
+	if labels["env"] != "prod" {
+		t.Errorf("expected static env label, got %q", labels["env"])
+	}
+}
+
+func Test_sendMetrics_Batches(t *testing.T) {
+	metrics := make([]models.Metrics, 7)
+	batchesChan := make(chan []models.Metrics, 10)
+
+	sendMetrics(metrics, 3, batchesChan)
+	close(batchesChan)
+
+	var sizes []int
+	for batch := range batchesChan {
+		sizes = append(sizes, len(batch))
+	}
+	if !reflect.DeepEqual(sizes, []int{3, 3, 1}) {
+		t.Errorf("expected batches of 3, 3, 1, got %v", sizes)
+	}
+}
+
+func Test_sendBatch(t *testing.T) {
+	var received []models.Metrics
+	var signature, timestamp, nonce, encoding, realIP, authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		signature = r.Header.Get(sign.HashSHA256)
+		timestamp = r.Header.Get(sign.TimestampHeader)
+		nonce = r.Header.Get(sign.NonceHeader)
+		encoding = r.Header.Get("Content-Encoding")
+		realIP = r.Header.Get(subnet.RealIPHeader)
+		authorization = r.Header.Get("Authorization")
+
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body is not gzipped: %v", err)
+			return
+		}
+		if err := json.NewDecoder(gz).Decode(&received); err != nil {
+			t.Errorf("body is not a JSON array: %v", err)
+		}
+		w.Header().Set(sign.HashSHA256, sign.GetHash(nil, []byte("secret")))
+	}))
+	defer server.Close()
+
+	worker := sentMetricWorker{
+		client: server.Client(),
+		conf:   &config.Config{RunAddr: strings.TrimPrefix(server.URL, "http://"), HashKey: "secret", Token: "agent-token"},
+		realIP: "192.168.1.10",
+	}
+	batch := []models.Metrics{
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1)},
+		{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(2)},
+	}
+
+	if err := worker.sendBatch(context.Background(), batch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body, _ := json.Marshal(batch)
+	if timestamp == "" || nonce == "" || signature != sign.GetRequestHash(body, []byte("secret"), timestamp, nonce) {
+		t.Errorf("unexpected signature %q", signature)
+	}
+	if realIP != "192.168.1.10" {
+		t.Errorf("unexpected X-Real-IP %q", realIP)
+	}
+	if authorization != "Bearer agent-token" {
+		t.Errorf("unexpected Authorization %q", authorization)
+	}
+	if encoding != "gzip" {
+		t.Errorf("expected gzip content encoding, got %q", encoding)
+	}
+	if !reflect.DeepEqual(received, batch) {
+		t.Errorf("expected %+v, got %+v", batch, received)
+	}
+}
+
+func Test_sendBatch_Encrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received []models.Metrics
+	handler := compressor.GzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("body is not a JSON array: %v", err)
+		}
+	}))
+	server := httptest.NewServer(crypto.DecryptionMiddleware(crypto.NewPrivateKeyHolder(privateKey))(handler))
+	defer server.Close()
+
+	worker := sentMetricWorker{
+		client:    server.Client(),
+		conf:      &config.Config{RunAddr: strings.TrimPrefix(server.URL, "http://")},
+		publicKey: &privateKey.PublicKey,
+	}
+
+	// The gzipped batch is well over the size a single RSA block can hold.
+	var batch []models.Metrics
+	for i := 0; i < 100; i++ {
+		batch = append(batch, models.Metrics{ID: fmt.Sprintf("Metric%d", i), MType: models.GaugeMetric, Value: utils.FloatPtr(float64(i) + 0.123456789)})
+	}
+
+	if err := worker.sendBatch(context.Background(), batch); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(received, batch) {
+		t.Errorf("expected the batch to be decrypted, got %d metrics", len(received))
+	}
+}
+
+func Test_sendBatch_ResponseSignature(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+	server := httptest.NewServer(compressor.GzipMiddleware(sign.HashMiddleware(handler)))
+	defer server.Close()
+
+	batch := []models.Metrics{{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(1)}}
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	sign.SetServerHashKey("secret")
+	defer sign.SetServerHashKey("")
+
+	worker := sentMetricWorker{client: server.Client(), conf: &config.Config{RunAddr: addr, HashKey: "secret"}}
+	if err := worker.sendBatch(context.Background(), batch); err != nil {
+		t.Fatalf("expected signed response to be accepted, got %v", err)
+	}
+
+	forged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(sign.HashSHA256, sign.GetHash([]byte(`{"status":"ok"}`), []byte("guess")))
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer forged.Close()
+
+	worker.client = forged.Client()
+	worker.conf = &config.Config{RunAddr: strings.TrimPrefix(forged.URL, "http://"), HashKey: "secret"}
+	err := worker.sendBatch(context.Background(), batch)
+	if !errors.Is(err, ErrInvalidResponseSignature) {
+		t.Fatalf("expected ErrInvalidResponseSignature, got %v", err)
+	}
+	if isRetryable(err) {
+		t.Error("expected a batch the server may have applied not to be retried")
+	}
+}
+
+func Test_sendMetric_UnverifiedResponseAcksCounters(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.Header().Set(sign.HashSHA256, "forged")
+	}))
+	defer server.Close()
+
+	counters := newCounterTracker()
+	workers := &atomic.Pointer[sentMetricWorker]{}
+	workers.Store(&sentMetricWorker{
+		client:   server.Client(),
+		conf:     &config.Config{RunAddr: strings.TrimPrefix(server.URL, "http://"), HashKey: "secret"},
+		counters: counters,
+	})
+
+	pollCount := []models.Metrics{{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(5)}}
+	batchesChan := make(chan []models.Metrics, 1)
+	resultsChan := make(chan error, 1)
+	batchesChan <- counters.Deltas(pollCount)
+	close(batchesChan)
+
+	sendMetric(context.Background(), workers, batchesChan, resultsChan)
+
+	if err := <-resultsChan; !errors.Is(err, ErrInvalidResponseSignature) || isRetryable(err) {
+		t.Fatalf("expected a non-retryable ErrInvalidResponseSignature, got %v", err)
+	}
+	if received != 1 {
+		t.Errorf("expected a single request, got %d", received)
+	}
+	if deltas := counters.Deltas(pollCount); len(deltas) != 0 {
+		t.Errorf("expected accepted deltas not to be sent again, got %+v", deltas)
+	}
+}
+
+func Test_deliver_QueuesWhenUnreachable(t *testing.T) {
+	outbox, err := OpenOutbox(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	addr := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	worker := sentMetricWorker{client: http.DefaultClient, conf: &config.Config{RunAddr: addr}, outbox: outbox}
+	batch := []models.Metrics{{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(1)}}
+
+	if err := worker.deliver(context.Background(), batch); err != nil {
+		t.Fatalf("expected batch to be queued, got %v", err)
+	}
+	if err := worker.deliver(context.Background(), batch); err != nil {
+		t.Fatalf("expected batch to be queued, got %v", err)
+	}
+	if outbox.Len() != 2 {
+		t.Fatalf("expected 2 queued batches, got %d", outbox.Len())
+	}
+
+	server = httptest.NewUnstartedServer(server.Config.Handler)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s again: %v", addr, err)
+	}
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	if err := outbox.Replay(func(batch []models.Metrics) error { return worker.sendBatch(context.Background(), batch) }); err != nil {
+		t.Fatalf("unexpected replay error: %v", err)
+	}
+	if received != 2 || outbox.Len() != 0 {
+		t.Errorf("expected 2 replayed batches, got %d received and %d queued", received, outbox.Len())
+	}
+}
+
+func Test_sendContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sendCtx, cancelSend := sendContext(ctx, 50*time.Millisecond)
+	defer cancelSend()
+
+	cancel()
+	if sendCtx.Err() != nil {
+		t.Fatal("expected the send context to outlive the agent context")
+	}
+
+	select {
+	case <-sendCtx.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the send context to be cancelled after the shutdown timeout")
+	}
+}
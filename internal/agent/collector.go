@@ -0,0 +1,289 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrUnknownCollector = errors.New("unknown collector")
+
+// Collector gathers a set of metrics. Counters are reported with their
+// cumulative values; the agent turns them into deltas when reporting.
+// Collectors holding resources may implement io.Closer; Close is called once
+// the collector is stopped.
+type Collector interface {
+	// Name identifies the collector in the configuration and in logs.
+	Name() string
+	// Collect returns the current metrics. On a partial failure it returns
+	// the metrics it could gather together with the error.
+	Collect(ctx context.Context) ([]models.Metrics, error)
+	// Interval is how often Collect is called; zero means the agent poll interval.
+	Interval() time.Duration
+}
+
+// CollectorFactory creates a collector from its "settings" configuration
+// object, which is nil when the collector has no settings.
+type CollectorFactory func(settings json.RawMessage) (Collector, error)
+
+type registration struct {
+	factory CollectorFactory
+	enabled bool
+}
+
+// Registry holds the collectors the agent can run.
+type Registry struct {
+	collectors map[string]registration
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: map[string]registration{}}
+}
+
+// DefaultRegistry creates a registry with the built-in collectors.
+func DefaultRegistry(conf *config.Config) *Registry {
+	registry := NewRegistry()
+	registry.Register(RuntimeCollectorName, true, func(json.RawMessage) (Collector, error) {
+		return NewRuntimeCollector(), nil
+	})
+	registry.Register(ProcCollectorName, procAvailable(conf.ProcRoot), newProcCollectorFactory(conf.ProcRoot))
+	registry.Register(ExecCollectorName, false, newExecCollector)
+	registry.Register(LogTailCollectorName, false, newLogTailCollector)
+	registry.Register(ProbeCollectorName, false, newProbeCollector)
+
+	return registry
+}
+
+// procAvailable reports whether the proc collector can run by default.
+func procAvailable(root string) bool {
+	if root == "" {
+		return false
+	}
+
+	_, err := os.Stat(root)
+	return err == nil
+}
+
+// decodeSettings decodes the "settings" object of a collector, rejecting
+// unknown fields. Missing settings leave the target untouched.
+func decodeSettings(raw json.RawMessage, target any) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(target)
+}
+
+// Register adds a collector; enabled tells whether it runs when the
+// configuration does not mention it. Registering a name again replaces it.
+func (r *Registry) Register(name string, enabled bool, factory CollectorFactory) {
+	r.collectors[name] = registration{factory: factory, enabled: enabled}
+}
+
+// Names returns the registered collector names in sorted order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Build creates the enabled collectors. If enabledNames is not empty it is
+// the exact set of collectors to run; otherwise the per-collector "enabled"
+// settings and the registration defaults decide.
+func (r *Registry) Build(configs map[string]config.CollectorConfig, enabledNames []string) ([]Collector, error) {
+	for name := range configs {
+		if _, ok := r.collectors[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+		}
+	}
+
+	enabled := make(map[string]bool, len(r.collectors))
+	for name, registration := range r.collectors {
+		enabled[name] = registration.enabled
+		if cfg, ok := configs[name]; ok && cfg.Enabled != nil {
+			enabled[name] = *cfg.Enabled
+		}
+	}
+	if len(enabledNames) > 0 {
+		clear(enabled)
+		for _, name := range enabledNames {
+			if _, ok := r.collectors[name]; !ok {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+			}
+			enabled[name] = true
+		}
+	}
+
+	var collectors []Collector
+	for _, name := range r.Names() {
+		if !enabled[name] {
+			continue
+		}
+
+		cfg := configs[name]
+		collector, err := r.collectors[name].factory(cfg.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("collector %s: %w", name, err)
+		}
+		if interval := cfg.Interval.Duration(); interval > 0 {
+			collector = intervalCollector{Collector: collector, interval: interval}
+		}
+
+		collectors = append(collectors, collector)
+	}
+
+	return collectors, nil
+}
+
+// intervalCollector overrides the interval of a collector from the configuration.
+type intervalCollector struct {
+	Collector
+	interval time.Duration
+}
+
+func (c intervalCollector) Interval() time.Duration {
+	return c.interval
+}
+
+func (c intervalCollector) Close() error {
+	if closer, ok := c.Collector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// collectorStore keeps the latest metrics of every collector.
+type collectorStore struct {
+	metrics map[string][]models.Metrics
+	mu      sync.Mutex
+}
+
+func newCollectorStore() *collectorStore {
+	return &collectorStore{metrics: map[string][]models.Metrics{}}
+}
+
+func (s *collectorStore) set(name string, metrics []models.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.metrics[name] = metrics
+}
+
+// retain drops the metrics of collectors other than the given ones.
+func (s *collectorStore) retain(collectors []Collector) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make(map[string]struct{}, len(collectors))
+	for _, collector := range collectors {
+		names[collector.Name()] = struct{}{}
+	}
+	for name := range s.metrics {
+		if _, ok := names[name]; !ok {
+			delete(s.metrics, name)
+		}
+	}
+}
+
+// Metrics returns the latest metrics of all collectors with the labels attached.
+func (s *collectorStore) Metrics(labels map[string]string) []models.Metrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.metrics))
+	for name := range s.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var metrics []models.Metrics
+	for _, name := range names {
+		for _, metric := range s.metrics[name] {
+			metrics = append(metrics, withLabels(metric, labels))
+		}
+	}
+
+	return metrics
+}
+
+// collectorGroup runs a set of collectors until it is stopped.
+type collectorGroup struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startCollectors runs every collector in its own goroutine.
+func startCollectors(ctx context.Context, collectors []Collector, defaultInterval time.Duration, store *collectorStore, telemetry *Telemetry) *collectorGroup {
+	ctx, cancel := context.WithCancel(ctx)
+	group := &collectorGroup{cancel: cancel}
+
+	for _, collector := range collectors {
+		logger.Log.Info("Starting collector", zap.String("collector", collector.Name()))
+		group.wg.Add(1)
+		go func() {
+			defer group.wg.Done()
+			runCollector(ctx, collector, defaultInterval, store, telemetry)
+		}()
+	}
+
+	return group
+}
+
+// stop stops the collectors and waits for them to return.
+func (g *collectorGroup) stop() {
+	g.cancel()
+	g.wg.Wait()
+}
+
+// runCollector collects on start and then every interval until the context
+// is cancelled, keeping the latest metrics in the store.
+func runCollector(ctx context.Context, collector Collector, defaultInterval time.Duration, store *collectorStore, telemetry *Telemetry) {
+	interval := collector.Interval()
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if closer, ok := collector.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	for {
+		start := time.Now()
+		metrics, err := collector.Collect(ctx)
+		telemetry.Collected(collector.Name(), time.Since(start), err)
+		if err != nil && ctx.Err() == nil {
+			logger.Log.Warn("Collector failed", zap.String("collector", collector.Name()), zap.Error(err))
+		}
+		if metrics != nil || err == nil {
+			store.set(collector.Name(), metrics)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
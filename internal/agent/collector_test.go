@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type staticCollector struct {
+	name    string
+	metrics []models.Metrics
+	calls   int
+}
+
+func (c *staticCollector) Name() string            { return c.name }
+func (c *staticCollector) Interval() time.Duration { return time.Hour }
+func (c *staticCollector) Collect(context.Context) ([]models.Metrics, error) {
+	c.calls++
+	return c.metrics, nil
+}
+
+func collectorNames(collectors []Collector) []string {
+	var names []string
+	for _, collector := range collectors {
+		names = append(names, collector.Name())
+	}
+	return names
+}
+
+func testRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register("on", true, func(json.RawMessage) (Collector, error) { return &staticCollector{name: "on"}, nil })
+	registry.Register("off", false, func(json.RawMessage) (Collector, error) { return &staticCollector{name: "off"}, nil })
+	return registry
+}
+
+func TestRegistry_Build(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name         string
+		configs      map[string]config.CollectorConfig
+		enabledNames []string
+		want         []string
+		wantErr      error
+	}{
+		{name: "defaults", want: []string{"on"}},
+		{name: "config toggles", configs: map[string]config.CollectorConfig{"on": {Enabled: &disabled}, "off": {Enabled: &enabled}}, want: []string{"off"}},
+		{name: "explicit list", enabledNames: []string{"off"}, want: []string{"off"}},
+		{name: "unknown in config", configs: map[string]config.CollectorConfig{"missing": {}}, wantErr: ErrUnknownCollector},
+		{name: "unknown in list", enabledNames: []string{"missing"}, wantErr: ErrUnknownCollector},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectors, err := testRegistry().Build(tt.configs, tt.enabledNames)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if got := collectorNames(collectors); tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want collectors %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRegistry_BuildSettings(t *testing.T) {
+	registry := DefaultRegistry(&config.Config{})
+	configs := map[string]config.CollectorConfig{
+		ProcCollectorName: {Interval: config.Duration(5 * time.Second), Settings: json.RawMessage(`{"root":"testdata/proc/first"}`)},
+	}
+
+	collectors, err := registry.Build(configs, []string{ProcCollectorName})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(collectors) != 1 || collectors[0].Interval() != 5*time.Second {
+		t.Fatalf("expected proc collector with 5s interval, got %+v", collectors)
+	}
+	if _, err := collectors[0].Collect(context.Background()); err != nil {
+		t.Errorf("expected settings root to be used, got %v", err)
+	}
+
+	configs[ProcCollectorName] = config.CollectorConfig{Settings: json.RawMessage(`{"rot":"/proc"}`)}
+	if _, err := registry.Build(configs, []string{ProcCollectorName}); err == nil {
+		t.Error("expected unknown settings field to be rejected")
+	}
+}
+
+func TestRunCollector(t *testing.T) {
+	collector := &staticCollector{name: "static", metrics: []models.Metrics{gauge("Temperature", 21, "room", "a")}}
+	store := newCollectorStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runCollector(ctx, collector, time.Second, store, nil)
+
+	if collector.calls != 1 {
+		t.Errorf("expected a collection on start, got %d", collector.calls)
+	}
+
+	metrics := store.Metrics(map[string]string{"env": "prod", "room": "ignored"})
+	if len(metrics) != 1 || metrics[0].Labels["env"] != "prod" || metrics[0].Labels["room"] != "a" {
+		t.Errorf("expected collector labels merged with agent labels, got %+v", metrics)
+	}
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	collector := NewRuntimeCollector()
+
+	_, _ = collector.Collect(context.Background())
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pollCount, ok := findMetric(metrics, "PollCount", "", "")
+	if !ok || *pollCount.Delta != 2 {
+		t.Errorf("expected cumulative PollCount 2, got %+v", pollCount)
+	}
+	if alloc, ok := findMetric(metrics, "Alloc", "", ""); !ok || *alloc.Value == 0 {
+		t.Error("expected non-zero Alloc")
+	}
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"sync"
+)
+
+// counterState holds the parts of a cumulative counter already reported.
+type counterState struct {
+	acked    int64 // Sum of deltas confirmed by the server
+	inFlight int64 // Sum of deltas sent but not confirmed yet
+}
+
+// counterTracker turns cumulative counter values into deltas. A delta is
+// the increment since everything acknowledged or in flight; it counts as
+// reported only after the server confirms it, and a failed delta is folded
+// into the next one.
+type counterTracker struct {
+	counters map[string]*counterState
+	mu       sync.Mutex
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{counters: map[string]*counterState{}}
+}
+
+// Deltas replaces the cumulative values of counters with their deltas and
+// marks them in flight. Counters that did not change are left out; gauges
+// are passed through.
+func (t *counterTracker) Deltas(metrics []models.Metrics) []models.Metrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]models.Metrics, 0, len(metrics))
+
+	for _, metric := range metrics {
+		if metric.MType != models.CounterMetric || metric.Delta == nil {
+			result = append(result, metric)
+			continue
+		}
+
+		state := t.state(metric)
+		total := *metric.Delta
+		reported := state.acked + state.inFlight
+
+		// The source restarted from zero: report its whole value. The acked
+		// part is rebased so that in-flight deltas still add up once confirmed.
+		if total < reported {
+			state.acked = -state.inFlight
+			reported = 0
+		}
+
+		delta := total - reported
+		if delta == 0 {
+			continue
+		}
+
+		state.inFlight += delta
+		metric.Delta = &delta
+		result = append(result, metric)
+	}
+
+	return result
+}
+
+// Ack commits the counter deltas of a batch the server accepted.
+func (t *counterTracker) Ack(metrics []models.Metrics) {
+	t.settle(metrics, true)
+}
+
+// Fail releases the counter deltas of a batch that was not delivered, so
+// they are sent again as part of the next deltas.
+func (t *counterTracker) Fail(metrics []models.Metrics) {
+	t.settle(metrics, false)
+}
+
+func (t *counterTracker) settle(metrics []models.Metrics, acked bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, metric := range metrics {
+		if metric.MType != models.CounterMetric || metric.Delta == nil {
+			continue
+		}
+
+		state := t.state(metric)
+		state.inFlight -= *metric.Delta
+		if acked {
+			state.acked += *metric.Delta
+		}
+	}
+}
+
+// state returns the state of the counter series; the caller must hold the mutex.
+func (t *counterTracker) state(metric models.Metrics) *counterState {
+	key := metric.ID + "\x00" + models.LabelsKey(metric.Labels)
+
+	state, ok := t.counters[key]
+	if !ok {
+		state = &counterState{}
+		t.counters[key] = state
+	}
+
+	return state
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
+	"testing"
+)
+
+func cumulative(id string, total int64, labels map[string]string) []models.Metrics {
+	return []models.Metrics{{ID: id, MType: models.CounterMetric, Delta: utils.IntPtr(total), Labels: labels}}
+}
+
+func deltaOf(t *testing.T, metrics []models.Metrics) int64 {
+	t.Helper()
+
+	if len(metrics) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(metrics))
+	}
+	return *metrics[0].Delta
+}
+
+func TestCounterTracker_AckedDeltas(t *testing.T) {
+	tracker := newCounterTracker()
+
+	first := tracker.Deltas(cumulative("PollCount", 5, nil))
+	if got := deltaOf(t, first); got != 5 {
+		t.Fatalf("expected delta 5, got %d", got)
+	}
+	tracker.Ack(first)
+
+	second := tracker.Deltas(cumulative("PollCount", 8, nil))
+	if got := deltaOf(t, second); got != 3 {
+		t.Fatalf("expected delta 3, got %d", got)
+	}
+	tracker.Ack(second)
+
+	if unchanged := tracker.Deltas(cumulative("PollCount", 8, nil)); len(unchanged) != 0 {
+		t.Errorf("expected unchanged counter to be skipped, got %+v", unchanged)
+	}
+}
+
+func TestCounterTracker_FailedDeltaIsResent(t *testing.T) {
+	tracker := newCounterTracker()
+
+	first := tracker.Deltas(cumulative("PollCount", 5, nil))
+	second := tracker.Deltas(cumulative("PollCount", 7, nil))
+	if got := deltaOf(t, second); got != 2 {
+		t.Fatalf("expected in-flight delta to be excluded, got %d", got)
+	}
+
+	tracker.Fail(first)
+	tracker.Ack(second)
+
+	third := tracker.Deltas(cumulative("PollCount", 9, nil))
+	if got := deltaOf(t, third); got != 7 {
+		t.Fatalf("expected failed delta 5 to be folded into 2, got %d", got)
+	}
+	tracker.Ack(third)
+
+	if state := tracker.state(third[0]); state.acked != 9 || state.inFlight != 0 {
+		t.Errorf("expected 9 acked and nothing in flight, got %+v", *state)
+	}
+}
+
+func TestCounterTracker_ResetAndSeries(t *testing.T) {
+	tracker := newCounterTracker()
+
+	a := tracker.Deltas(cumulative("Errors", 10, map[string]string{"host": "a"}))
+	b := tracker.Deltas(cumulative("Errors", 4, map[string]string{"host": "b"}))
+	if deltaOf(t, a) != 10 || deltaOf(t, b) != 4 {
+		t.Fatalf("expected separate series, got %d and %d", deltaOf(t, a), deltaOf(t, b))
+	}
+	tracker.Ack(a)
+
+	reset := tracker.Deltas(cumulative("Errors", 3, map[string]string{"host": "a"}))
+	if got := deltaOf(t, reset); got != 3 {
+		t.Errorf("expected delta 3 after reset, got %d", got)
+	}
+}
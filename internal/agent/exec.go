@@ -0,0 +1,338 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/models"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecCollectorName is the name of the script collector.
+const ExecCollectorName = "exec"
+
+// Script collector defaults and limits.
+const (
+	DefaultScriptTimeout = 10 * time.Second
+	maxScriptOutput      = 1 << 20
+)
+
+// Self-metrics reported for every script, labeled with the script name.
+const (
+	ExecSuccessMetric  = "ExecScriptSuccess"
+	ExecDurationMetric = "ExecScriptDuration"
+	ExecFailuresMetric = "ExecScriptFailures"
+)
+
+var (
+	ErrEmptyScriptName      = errors.New("script name is empty")
+	ErrDuplicateScriptName  = errors.New("duplicate script name")
+	ErrEmptyScriptCommand   = errors.New("script command is empty")
+	ErrInvalidScriptOutput  = errors.New("invalid script output")
+	ErrScriptOutputTooLarge = errors.New("script output is too large")
+)
+
+// ScriptConfig describes a script run by the exec collector.
+type ScriptConfig struct {
+	Name    string            `json:"name"`    // Unique script name, reported in the "script" label
+	Command string            `json:"command"` // Executable to run
+	Args    []string          `json:"args"`    // Command arguments
+	Dir     string            `json:"dir"`     // Working directory
+	Env     map[string]string `json:"env"`     // Variables added to the agent environment
+	Timeout config.Duration   `json:"timeout"` // Maximum run time
+	Labels  map[string]string `json:"labels"`  // Labels added to every metric of the script
+}
+
+// execSettings is the "settings" object of the exec collector.
+type execSettings struct {
+	Scripts []ScriptConfig `json:"scripts"`
+}
+
+// ExecCollector runs scripts and parses their stdout as metrics, either
+// "name type value" lines or JSON in the metrics shape. Every metric gets
+// the "script" label, so scripts printing the same names do not share a
+// series. Counter values are non-negative increments of a single run and
+// are accumulated by the collector. Every script also reports whether its
+// last run succeeded, how long it took and the number of failed runs.
+type ExecCollector struct {
+	scripts  []ScriptConfig
+	counters map[string]models.Metrics
+	failures map[string]int64
+	mu       sync.Mutex
+}
+
+// NewExecCollector validates the scripts and creates a collector for them.
+func NewExecCollector(scripts []ScriptConfig) (*ExecCollector, error) {
+	names := make(map[string]struct{}, len(scripts))
+
+	for i, script := range scripts {
+		if script.Name == "" {
+			return nil, fmt.Errorf("script #%d: %w", i, ErrEmptyScriptName)
+		}
+		if script.Command == "" {
+			return nil, fmt.Errorf("script %q: %w", script.Name, ErrEmptyScriptCommand)
+		}
+		if _, ok := names[script.Name]; ok {
+			return nil, fmt.Errorf("script %q: %w", script.Name, ErrDuplicateScriptName)
+		}
+		names[script.Name] = struct{}{}
+	}
+
+	return &ExecCollector{
+		scripts:  scripts,
+		counters: map[string]models.Metrics{},
+		failures: map[string]int64{},
+	}, nil
+}
+
+func newExecCollector(raw json.RawMessage) (Collector, error) {
+	var settings execSettings
+	if err := decodeSettings(raw, &settings); err != nil {
+		return nil, err
+	}
+
+	return NewExecCollector(settings.Scripts)
+}
+
+func (c *ExecCollector) Name() string {
+	return ExecCollectorName
+}
+
+func (c *ExecCollector) Interval() time.Duration {
+	return 0
+}
+
+type scriptResult struct {
+	metrics  []models.Metrics
+	duration time.Duration
+	err      error
+}
+
+// Collect runs all scripts concurrently. Failed scripts are reported in the
+// returned error and in their self-metrics.
+func (c *ExecCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	results := make([]scriptResult, len(c.scripts))
+
+	var wg sync.WaitGroup
+	for i, script := range c.scripts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			metrics, err := runScript(ctx, script)
+			results[i] = scriptResult{metrics: metrics, duration: time.Since(start), err: err}
+		}()
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var metrics []models.Metrics
+	var errs []error
+
+	for i, script := range c.scripts {
+		result := results[i]
+
+		success := 1.0
+		if result.err != nil {
+			success = 0
+			c.failures[script.Name]++
+			errs = append(errs, fmt.Errorf("script %q: %w", script.Name, result.err))
+		}
+
+		for _, metric := range result.metrics {
+			metric = withLabels(metric, script.Labels)
+			// The script label always wins, so a script cannot write into
+			// the series of another one.
+			metric = withLabels(metric, map[string]string{"script": ""})
+			metric.Labels["script"] = script.Name
+			if metric.MType == models.CounterMetric {
+				c.addCounter(metric)
+				continue
+			}
+			metrics = append(metrics, metric)
+		}
+
+		failures := c.failures[script.Name]
+		metrics = append(metrics,
+			gauge(ExecSuccessMetric, success, "script", script.Name),
+			gauge(ExecDurationMetric, result.duration.Seconds(), "script", script.Name),
+			models.Metrics{ID: ExecFailuresMetric, MType: models.CounterMetric, Delta: &failures, Labels: map[string]string{"script": script.Name}},
+		)
+	}
+
+	for _, counter := range c.counters {
+		total := *counter.Delta
+		counter.Delta = &total
+		metrics = append(metrics, counter)
+	}
+
+	return metrics, errors.Join(errs...)
+}
+
+// addCounter adds the increment to the cumulative counter; the caller must
+// hold the mutex.
+func (c *ExecCollector) addCounter(metric models.Metrics) {
+	key := metric.ID + "\x00" + models.LabelsKey(metric.Labels)
+
+	total := *metric.Delta
+	if counter, ok := c.counters[key]; ok {
+		total += *counter.Delta
+	}
+	metric.Delta = &total
+	c.counters[key] = metric
+}
+
+func runScript(ctx context.Context, script ScriptConfig) ([]models.Metrics, error) {
+	timeout := script.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = DefaultScriptTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, script.Command, script.Args...)
+	cmd.Dir = script.Dir
+	cmd.Env = os.Environ()
+	for name, value := range script.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.WaitDelay = time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &limitedWriter{buf: &stdout, limit: maxScriptOutput}
+	cmd.Stderr = &limitedWriter{buf: &stderr, limit: 4096}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timed out after %s", timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	if stdout.Len() >= maxScriptOutput {
+		return nil, ErrScriptOutputTooLarge
+	}
+
+	return ParseScriptOutput(stdout.Bytes())
+}
+
+// ParseScriptOutput parses script output: a JSON metric or array of metrics,
+// or "name type value" lines where blank lines and lines starting with "#"
+// are ignored.
+func ParseScriptOutput(output []byte) ([]models.Metrics, error) {
+	trimmed := bytes.TrimSpace(output)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	var metrics []models.Metrics
+	switch trimmed[0] {
+	case '[':
+		if err := json.Unmarshal(trimmed, &metrics); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidScriptOutput, err)
+		}
+	case '{':
+		var metric models.Metrics
+		if err := json.Unmarshal(trimmed, &metric); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidScriptOutput, err)
+		}
+		metrics = append(metrics, metric)
+	default:
+		return parseScriptLines(trimmed)
+	}
+
+	for _, metric := range metrics {
+		if err := validateScriptMetric(metric); err != nil {
+			return nil, err
+		}
+	}
+
+	return metrics, nil
+}
+
+func parseScriptLines(output []byte) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: line %d: expected \"name type value\"", ErrInvalidScriptOutput, line)
+		}
+
+		metric := models.Metrics{ID: fields[0], MType: fields[1]}
+		switch metric.MType {
+		case models.GaugeMetric:
+			value, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidScriptOutput, line, err)
+			}
+			metric.Value = &value
+		case models.CounterMetric:
+			delta, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidScriptOutput, line, err)
+			}
+			if delta < 0 {
+				return nil, fmt.Errorf("%w: line %d: counter %s is negative", ErrInvalidScriptOutput, line, metric.ID)
+			}
+			metric.Delta = &delta
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown metric type %q", ErrInvalidScriptOutput, line, metric.MType)
+		}
+
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, scanner.Err()
+}
+
+func validateScriptMetric(metric models.Metrics) error {
+	switch {
+	case metric.ID == "":
+		return fmt.Errorf("%w: metric id is empty", ErrInvalidScriptOutput)
+	case metric.MType == models.GaugeMetric && metric.Value == nil:
+		return fmt.Errorf("%w: gauge %s has no value", ErrInvalidScriptOutput, metric.ID)
+	case metric.MType == models.CounterMetric && metric.Delta == nil:
+		return fmt.Errorf("%w: counter %s has no delta", ErrInvalidScriptOutput, metric.ID)
+	case metric.MType == models.CounterMetric && *metric.Delta < 0:
+		return fmt.Errorf("%w: counter %s is negative", ErrInvalidScriptOutput, metric.ID)
+	case metric.MType != models.GaugeMetric && metric.MType != models.CounterMetric:
+		return fmt.Errorf("%w: unknown metric type %q", ErrInvalidScriptOutput, metric.MType)
+	}
+
+	return nil
+}
+
+// limitedWriter keeps at most limit bytes and discards the rest, so a
+// runaway script cannot exhaust the agent memory.
+type limitedWriter struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if room := w.limit - w.buf.Len(); room > 0 {
+		w.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestParseScriptOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int
+		wantErr error
+	}{
+		{name: "empty", output: "  \n", want: 0},
+		{name: "lines", output: "# comment\nqueue_depth gauge 12.5\n\nerrors counter 3\n", want: 2},
+		{name: "json array", output: `[{"id":"a","type":"gauge","value":1},{"id":"b","type":"counter","delta":2,"labels":{"k":"v"}}]`, want: 2},
+		{name: "json object", output: `{"id":"a","type":"gauge","value":1}`, want: 1},
+		{name: "missing field", output: "queue_depth gauge", wantErr: ErrInvalidScriptOutput},
+		{name: "bad type", output: "queue_depth histogram 1", wantErr: ErrInvalidScriptOutput},
+		{name: "fractional counter", output: "errors counter 1.5", wantErr: ErrInvalidScriptOutput},
+		{name: "negative counter", output: "errors counter -1", wantErr: ErrInvalidScriptOutput},
+		{name: "negative json counter", output: `{"id":"errors","type":"counter","delta":-1}`, wantErr: ErrInvalidScriptOutput},
+		{name: "gauge without value", output: `[{"id":"a","type":"gauge"}]`, wantErr: ErrInvalidScriptOutput},
+		{name: "broken json", output: `[{"id":`, wantErr: ErrInvalidScriptOutput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := ParseScriptOutput([]byte(tt.output))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if len(metrics) != tt.want {
+				t.Errorf("want %d metrics, got %d", tt.want, len(metrics))
+			}
+		})
+	}
+}
+
+func TestNewExecCollector_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		scripts []ScriptConfig
+		wantErr error
+	}{
+		{name: "empty name", scripts: []ScriptConfig{{Command: "true"}}, wantErr: ErrEmptyScriptName},
+		{name: "empty command", scripts: []ScriptConfig{{Name: "a"}}, wantErr: ErrEmptyScriptCommand},
+		{name: "duplicate", scripts: []ScriptConfig{{Name: "a", Command: "true"}, {Name: "a", Command: "true"}}, wantErr: ErrDuplicateScriptName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewExecCollector(tt.scripts); !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExecCollector_Collect(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir := t.TempDir()
+	collector, err := NewExecCollector([]ScriptConfig{
+		{
+			Name:    "queue",
+			Command: "sh",
+			Args:    []string{"-c", `echo "queue_depth gauge $DEPTH"; echo "jobs counter 2"; test "$(pwd)" = "$EXPECTED_DIR"`},
+			Dir:     dir,
+			Env:     map[string]string{"DEPTH": "7", "EXPECTED_DIR": dir},
+			Labels:  map[string]string{"queue": "mail"},
+		},
+		{
+			Name:    "slow",
+			Command: "sh",
+			Args:    []string{"-c", "exec sleep 5"},
+			Timeout: config.Duration(50 * time.Millisecond),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _ = collector.Collect(context.Background())
+	metrics, err := collector.Collect(context.Background())
+	if err == nil {
+		t.Error("expected the slow script to fail")
+	}
+
+	depth, ok := findMetric(metrics, "queue_depth", "queue", "mail")
+	if !ok || *depth.Value != 7 {
+		t.Errorf("expected queue_depth 7 with script labels, got %+v", depth)
+	}
+	assertCounter(t, metrics, "jobs", "queue", "mail", 4)
+	assertGauge(t, metrics, ExecSuccessMetric, "script", "queue", 1)
+	assertGauge(t, metrics, ExecSuccessMetric, "script", "slow", 0)
+	assertCounter(t, metrics, ExecFailuresMetric, "script", "slow", 2)
+	assertCounter(t, metrics, ExecFailuresMetric, "script", "queue", 0)
+
+	if duration, ok := findMetric(metrics, ExecDurationMetric, "script", "slow"); !ok || *duration.Value > 2 {
+		t.Errorf("expected the slow script to be killed on timeout, got %+v", duration)
+	}
+	if _, ok := findMetric(metrics, "queue_depth", "script", "slow"); ok {
+		t.Error("unexpected metrics from the failed script")
+	}
+}
+
+func TestExecCollector_JSONOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	collector, err := NewExecCollector([]ScriptConfig{{
+		Name:    "json",
+		Command: "sh",
+		Args:    []string{"-c", `echo '[{"id":"temp","type":"gauge","value":21.5,"labels":{"room":"a"}}]'`},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertGauge(t, metrics, "temp", "room", "a", 21.5)
+	assertGauge(t, metrics, ExecSuccessMetric, "script", "json", 1)
+}
+
+func TestExecCollector_ScriptLabel(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	collector, err := NewExecCollector([]ScriptConfig{
+		{Name: "first", Command: "sh", Args: []string{"-c", "echo 'errors counter 1'"}},
+		{Name: "second", Command: "sh", Args: []string{"-c", "echo 'errors counter 2'"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertCounter(t, metrics, "errors", "script", "first", 1)
+	assertCounter(t, metrics, "errors", "script", "second", 2)
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+// Labels detected automatically and attached to every metric. They only
+// hold values that survive an agent restart, so a restart continues the
+// same series.
+const (
+	LabelHost     = "host"
+	LabelInstance = "instance"
+)
+
+// hostLabels returns the auto-detected identity labels merged with the
+// static labels; static labels override detected ones. The instance is the
+// local IP used to reach the server, falling back to the hostname.
+func hostLabels(serverAddr string, static map[string]string) map[string]string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Log.Warn("Failed to detect hostname", zap.Error(err))
+	}
+
+	instance := hostname
+	if ip, err := utils.OutboundIP(serverAddr); err == nil {
+		instance = ip.String()
+	} else {
+		logger.Log.Warn("Failed to detect outbound IP", zap.Error(err))
+	}
+
+	labels := map[string]string{}
+	if hostname != "" {
+		labels[LabelHost] = hostname
+	}
+	if instance != "" {
+		labels[LabelInstance] = instance
+	}
+
+	for name, value := range static {
+		labels[name] = value
+	}
+
+	return labels
+}
+
+// withLabels attaches a copy of the labels to the metric; labels the
+// metric already has take precedence.
+func withLabels(metric models.Metrics, labels map[string]string) models.Metrics {
+	if len(labels) == 0 {
+		return metric
+	}
+
+	merged := make(map[string]string, len(labels)+len(metric.Labels))
+	for name, value := range labels {
+		merged[name] = value
+	}
+	for name, value := range metric.Labels {
+		merged[name] = value
+	}
+	metric.Labels = merged
+
+	return metric
+}
@@ -0,0 +1,376 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// LogTailCollectorName is the name of the log tailing collector.
+const LogTailCollectorName = "logtail"
+
+// Log lines longer than maxLogLine are dropped.
+const (
+	logReadChunk = 64 << 10
+	maxLogLine   = 64 << 10
+)
+
+var (
+	ErrEmptyLogPath       = errors.New("log file path is empty")
+	ErrEmptyLogPattern    = errors.New("log pattern needs a counter or a gauge name")
+	ErrMissingLogGroup    = errors.New("log pattern gauge needs a capture group")
+	ErrUnknownLogGroup    = errors.New("log pattern capture group is not in the regex")
+	ErrDuplicateLogMetric = errors.New("duplicate log pattern metric")
+)
+
+// LogPatternConfig describes a regex matched against every new log line.
+type LogPatternConfig struct {
+	Regex   string `json:"regex"`   // Regular expression matched against each line
+	Counter string `json:"counter"` // Counter incremented on every match
+	Gauge   string `json:"gauge"`   // Gauge set to the captured value of the last match
+	Group   string `json:"group"`   // Named capture group feeding the gauge
+}
+
+// LogFileConfig describes a tailed log file.
+type LogFileConfig struct {
+	Path          string             `json:"path"`           // File to follow
+	FromBeginning bool               `json:"from_beginning"` // Read the existing content on start
+	Labels        map[string]string  `json:"labels"`         // Labels added to the metrics of the file
+	Patterns      []LogPatternConfig `json:"patterns"`       // Patterns matched against new lines
+}
+
+// logTailSettings is the "settings" object of the log tail collector.
+type logTailSettings struct {
+	Files []LogFileConfig `json:"files"`
+}
+
+type logPattern struct {
+	regex   *regexp.Regexp
+	counter string
+	gauge   string
+	group   int
+	count   int64
+	value   *float64
+}
+
+// logTail follows a single file by path. A different file at the path
+// means the log was rotated: the old file is read to its end before the new
+// one is read from the start. A file shorter than the read offset was
+// truncated and is read again from the start.
+type logTail struct {
+	path       string
+	labels     map[string]string
+	patterns   []*logPattern
+	file       *os.File
+	info       os.FileInfo
+	offset     int64
+	partial    []byte
+	discarding bool // Skipping the rest of a line longer than maxLogLine
+	skipOld    bool
+}
+
+// LogTailCollector tails log files and derives metrics from regex matches:
+// every match increments a counter, and a named capture group can feed a
+// gauge with the value of the last match.
+type LogTailCollector struct {
+	tails []*logTail
+}
+
+// NewLogTailCollector validates the files and creates a collector for them.
+func NewLogTailCollector(files []LogFileConfig) (*LogTailCollector, error) {
+	collector := &LogTailCollector{}
+
+	for i, file := range files {
+		if file.Path == "" {
+			return nil, fmt.Errorf("log file #%d: %w", i, ErrEmptyLogPath)
+		}
+
+		tail := &logTail{
+			path:    file.Path,
+			labels:  map[string]string{"file": file.Path},
+			skipOld: !file.FromBeginning,
+		}
+		for name, value := range file.Labels {
+			tail.labels[name] = value
+		}
+
+		names := map[string]struct{}{}
+		for j, pattern := range file.Patterns {
+			compiled, err := newLogPattern(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("log file %s, pattern #%d: %w", file.Path, j, err)
+			}
+			for _, name := range []string{compiled.counter, compiled.gauge} {
+				if name == "" {
+					continue
+				}
+				if _, ok := names[name]; ok {
+					return nil, fmt.Errorf("log file %s, pattern #%d: %w: %s", file.Path, j, ErrDuplicateLogMetric, name)
+				}
+				names[name] = struct{}{}
+			}
+			tail.patterns = append(tail.patterns, compiled)
+		}
+
+		collector.tails = append(collector.tails, tail)
+	}
+
+	return collector, nil
+}
+
+func newLogPattern(cfg LogPatternConfig) (*logPattern, error) {
+	if cfg.Counter == "" && cfg.Gauge == "" {
+		return nil, ErrEmptyLogPattern
+	}
+	if cfg.Gauge != "" && cfg.Group == "" {
+		return nil, ErrMissingLogGroup
+	}
+
+	regex, err := regexp.Compile(cfg.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern := &logPattern{regex: regex, counter: cfg.Counter, gauge: cfg.Gauge, group: -1}
+	if cfg.Group != "" {
+		if pattern.group = regex.SubexpIndex(cfg.Group); pattern.group < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownLogGroup, cfg.Group)
+		}
+	}
+
+	return pattern, nil
+}
+
+func newLogTailCollector(raw json.RawMessage) (Collector, error) {
+	var settings logTailSettings
+	if err := decodeSettings(raw, &settings); err != nil {
+		return nil, err
+	}
+
+	return NewLogTailCollector(settings.Files)
+}
+
+func (c *LogTailCollector) Name() string {
+	return LogTailCollectorName
+}
+
+func (c *LogTailCollector) Interval() time.Duration {
+	return 0
+}
+
+// Collect reads the lines appended since the previous collection.
+// Counters are cumulative since the collector was created; gauges keep the
+// value of the last match.
+func (c *LogTailCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	var errs []error
+
+	for _, tail := range c.tails {
+		if err := ctx.Err(); err != nil {
+			return metrics, err
+		}
+		if err := tail.poll(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tail.path, err))
+		}
+		metrics = append(metrics, tail.metrics()...)
+	}
+
+	return metrics, errors.Join(errs...)
+}
+
+// Close closes the followed files.
+func (c *LogTailCollector) Close() error {
+	for _, tail := range c.tails {
+		tail.close()
+	}
+	return nil
+}
+
+func (t *logTail) metrics() []models.Metrics {
+	var metrics []models.Metrics
+
+	for _, pattern := range t.patterns {
+		if pattern.counter != "" {
+			count := pattern.count
+			metrics = append(metrics, withLabels(models.Metrics{ID: pattern.counter, MType: models.CounterMetric, Delta: &count}, t.labels))
+		}
+		if pattern.gauge != "" && pattern.value != nil {
+			value := *pattern.value
+			metrics = append(metrics, withLabels(models.Metrics{ID: pattern.gauge, MType: models.GaugeMetric, Value: &value}, t.labels))
+		}
+	}
+
+	return metrics
+}
+
+// poll reads new lines, following truncation and rotation.
+func (t *logTail) poll() error {
+	if t.file == nil {
+		if err := t.open(t.skipOld); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// A file created after the start is read from its beginning.
+				t.skipOld = false
+			}
+			return err
+		}
+	}
+
+	if info, err := t.file.Stat(); err == nil && info.Size() < t.offset {
+		if err := t.rewind(); err != nil {
+			return err
+		}
+	}
+
+	if err := t.read(); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(t.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Rotated away and not recreated yet: keep the old file, which
+			// may still be written to, and look for the new one next time.
+			return nil
+		}
+		return err
+	}
+	if os.SameFile(t.info, current) {
+		return nil
+	}
+
+	t.close()
+	if err := t.open(false); err != nil {
+		return err
+	}
+	return t.read()
+}
+
+// open opens the file at the path, at its end if skipOld is set. The old
+// content is skipped only for the file found on start.
+func (t *logTail) open(skipOld bool) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	offset := int64(0)
+	if skipOld {
+		if offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	t.file, t.info, t.offset, t.partial, t.discarding = file, info, offset, nil, false
+	t.skipOld = false
+	return nil
+}
+
+func (t *logTail) rewind() error {
+	if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	t.offset, t.partial, t.discarding = 0, nil, false
+	return nil
+}
+
+func (t *logTail) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// read processes the complete lines appended since the last read; an
+// unterminated last line is kept until its end is written.
+func (t *logTail) read() error {
+	buf := make([]byte, logReadChunk)
+
+	for {
+		n, err := t.file.Read(buf)
+		if n > 0 {
+			t.offset += int64(n)
+			t.consume(buf[:n])
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// consume matches the complete lines of data. A line that grows longer than
+// maxLogLine is dropped, up to and including its newline.
+func (t *logTail) consume(data []byte) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if t.discarding {
+			if i < 0 {
+				return
+			}
+			t.discarding = false
+			data = data[i+1:]
+			continue
+		}
+
+		if i < 0 {
+			if len(t.partial)+len(data) <= maxLogLine {
+				t.partial = append(t.partial, data...)
+			} else {
+				t.partial, t.discarding = nil, true
+			}
+			return
+		}
+
+		if len(t.partial)+i > maxLogLine {
+			t.partial = nil
+			data = data[i+1:]
+			continue
+		}
+
+		line := data[:i]
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		t.match(bytes.TrimSuffix(line, []byte{'\r'}))
+		data = data[i+1:]
+	}
+}
+
+func (t *logTail) match(line []byte) {
+	for _, pattern := range t.patterns {
+		if pattern.group < 0 {
+			if pattern.regex.Match(line) {
+				pattern.count++
+			}
+			continue
+		}
+
+		submatch := pattern.regex.FindSubmatch(line)
+		if submatch == nil {
+			continue
+		}
+		pattern.count++
+
+		if value, err := strconv.ParseFloat(string(submatch[pattern.group]), 64); err == nil {
+			pattern.value = &value
+		}
+	}
+}
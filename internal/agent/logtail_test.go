@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func appendLog(t *testing.T, path, text string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(text); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func collectLog(t *testing.T, collector *LogTailCollector) ([]models.Metrics, error) {
+	t.Helper()
+	return collector.Collect(context.Background())
+}
+
+func newTestLogTail(t *testing.T, path string, fromBeginning bool) *LogTailCollector {
+	t.Helper()
+
+	collector, err := NewLogTailCollector([]LogFileConfig{{
+		Path:          path,
+		FromBeginning: fromBeginning,
+		Labels:        map[string]string{"app": "api"},
+		Patterns: []LogPatternConfig{
+			{Regex: "ERROR", Counter: "AppErrors"},
+			{Regex: `latency=(?P<ms>[0-9.]+)ms`, Counter: "Requests", Gauge: "RequestLatency", Group: "ms"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return collector
+}
+
+func TestLogTailCollector_Matches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "ERROR old line\n")
+
+	collector := newTestLogTail(t, path, false)
+	metrics, err := collectLog(t, collector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertCounter(t, metrics, "AppErrors", "app", "api", 0)
+	if _, ok := findMetric(metrics, "RequestLatency", "", ""); ok {
+		t.Error("expected no latency gauge before a match")
+	}
+
+	appendLog(t, path, "INFO latency=12.5ms\nERROR boom\nINFO latency=7ms\nERROR partial")
+	metrics, _ = collectLog(t, collector)
+	assertCounter(t, metrics, "AppErrors", "file", path, 1)
+	assertCounter(t, metrics, "Requests", "app", "api", 2)
+	assertGauge(t, metrics, "RequestLatency", "app", "api", 7)
+
+	appendLog(t, path, " line\n")
+	metrics, _ = collectLog(t, collector)
+	assertCounter(t, metrics, "AppErrors", "app", "api", 2)
+}
+
+func TestLogTailCollector_FromBeginning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "ERROR one\r\nERROR two\n")
+
+	metrics, _ := collectLog(t, newTestLogTail(t, path, true))
+	assertCounter(t, metrics, "AppErrors", "app", "api", 2)
+}
+
+func TestLogTailCollector_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLog(t, path, "")
+
+	collector := newTestLogTail(t, path, false)
+	_, _ = collectLog(t, collector)
+
+	appendLog(t, path, "ERROR before rotation\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	appendLog(t, path+".1", "ERROR written late to the old file\n")
+
+	metrics, err := collectLog(t, collector)
+	if err != nil {
+		t.Fatalf("unexpected error while the file is missing: %v", err)
+	}
+	assertCounter(t, metrics, "AppErrors", "app", "api", 2)
+
+	appendLog(t, path, "ERROR in the new file\nERROR again\n")
+	metrics, _ = collectLog(t, collector)
+	assertCounter(t, metrics, "AppErrors", "app", "api", 4)
+}
+
+func TestLogTailCollector_Truncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+
+	collector := newTestLogTail(t, path, false)
+	_, _ = collectLog(t, collector)
+	appendLog(t, path, "ERROR one\nERROR two\nERROR three\n")
+	_, _ = collectLog(t, collector)
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	appendLog(t, path, "ERROR x\n")
+
+	metrics, _ := collectLog(t, collector)
+	assertCounter(t, metrics, "AppErrors", "app", "api", 4)
+}
+
+func TestLogTailCollector_LongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLog(t, path, "")
+
+	collector := newTestLogTail(t, path, false)
+	_, _ = collectLog(t, collector)
+
+	// The head of the line is read in one collection and its tail, which
+	// matches, in the next one.
+	appendLog(t, path, strings.Repeat("x", maxLogLine+1))
+	_, _ = collectLog(t, collector)
+	appendLog(t, path, " ERROR in the tail of a long line\n")
+	metrics, _ := collectLog(t, collector)
+	assertCounter(t, metrics, "AppErrors", "app", "api", 0)
+
+	appendLog(t, path, strings.Repeat("x", 2*maxLogLine)+" ERROR\nERROR next line\n")
+	metrics, _ = collectLog(t, collector)
+	assertCounter(t, metrics, "AppErrors", "app", "api", 1)
+}
+
+func TestLogTailCollector_MissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	collector := newTestLogTail(t, path, false)
+
+	if _, err := collectLog(t, collector); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected missing file error, got %v", err)
+	}
+
+	appendLog(t, path, "ERROR first\n")
+	metrics, err := collectLog(t, collector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertCounter(t, metrics, "AppErrors", "app", "api", 1)
+}
+
+func TestNewLogTailCollector_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    LogFileConfig
+		wantErr error
+	}{
+		{name: "empty path", file: LogFileConfig{}, wantErr: ErrEmptyLogPath},
+		{name: "no metric", file: LogFileConfig{Path: "a", Patterns: []LogPatternConfig{{Regex: "x"}}}, wantErr: ErrEmptyLogPattern},
+		{name: "gauge without group", file: LogFileConfig{Path: "a", Patterns: []LogPatternConfig{{Regex: "x", Gauge: "g"}}}, wantErr: ErrMissingLogGroup},
+		{name: "unknown group", file: LogFileConfig{Path: "a", Patterns: []LogPatternConfig{{Regex: "(?P<v>x)", Gauge: "g", Group: "w"}}}, wantErr: ErrUnknownLogGroup},
+		{name: "duplicate metric", file: LogFileConfig{Path: "a", Patterns: []LogPatternConfig{{Regex: "x", Counter: "c"}, {Regex: "y", Counter: "c"}}}, wantErr: ErrDuplicateLogMetric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLogTailCollector([]LogFileConfig{tt.file}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"context"
+	"runtime"
+	"time"
+)
+
+// RuntimeCollectorName is the name of the Go runtime collector.
+const RuntimeCollectorName = "runtime"
+
+type stats map[string]float64
+
+// RuntimeCollector reports the Go runtime memory statistics of the agent
+// and the PollCount counter of its collections.
+type RuntimeCollector struct {
+	memStat   runtime.MemStats
+	pollCount int64
+}
+
+// NewRuntimeCollector creates a Go runtime collector.
+func NewRuntimeCollector() *RuntimeCollector {
+	return &RuntimeCollector{}
+}
+
+func (c *RuntimeCollector) Name() string {
+	return RuntimeCollectorName
+}
+
+func (c *RuntimeCollector) Interval() time.Duration {
+	return 0
+}
+
+func (c *RuntimeCollector) Collect(_ context.Context) ([]models.Metrics, error) {
+	c.pollCount++
+	runtime.ReadMemStats(&c.memStat)
+
+	stats := make(stats)
+	getMemStatData(&c.memStat, stats)
+
+	metrics := make([]models.Metrics, 0, len(stats)+1)
+	for key, value := range stats {
+		metrics = append(metrics, gauge(key, value))
+	}
+
+	pollCount := c.pollCount
+	metrics = append(metrics, models.Metrics{ID: "PollCount", MType: models.CounterMetric, Delta: &pollCount})
+
+	return metrics, nil
+}
+
+func getMemStatData(memStat *runtime.MemStats, stats stats) {
+	stats["Alloc"] = float64(memStat.Alloc)
+	stats["BuckHashSys"] = float64(memStat.BuckHashSys)
+	stats["Frees"] = float64(memStat.Frees)
+	stats["GCCPUFraction"] = float64(memStat.GCCPUFraction)
+	stats["GCSys"] = float64(memStat.GCSys)
+	stats["HeapAlloc"] = float64(memStat.HeapAlloc)
+	stats["HeapIdle"] = float64(memStat.HeapIdle)
+	stats["HeapInuse"] = float64(memStat.HeapInuse)
+	stats["HeapObjects"] = float64(memStat.HeapObjects)
+	stats["HeapReleased"] = float64(memStat.HeapReleased)
+	stats["HeapSys"] = float64(memStat.HeapSys)
+	stats["LastGC"] = float64(memStat.LastGC)
+	stats["Lookups"] = float64(memStat.Lookups)
+	stats["MCacheInuse"] = float64(memStat.MCacheInuse)
+	stats["MCacheSys"] = float64(memStat.MCacheSys)
+	stats["MSpanInuse"] = float64(memStat.MSpanInuse)
+	stats["MSpanSys"] = float64(memStat.MSpanSys)
+	stats["Mallocs"] = float64(memStat.Mallocs)
+	stats["NextGC"] = float64(memStat.NextGC)
+	stats["NumForcedGC"] = float64(memStat.NumForcedGC)
+	stats["NumGC"] = float64(memStat.NumGC)
+	stats["OtherSys"] = float64(memStat.OtherSys)
+	stats["PauseTotalNs"] = float64(memStat.PauseTotalNs)
+	stats["StackInuse"] = float64(memStat.StackInuse)
+	stats["StackSys"] = float64(memStat.StackSys)
+	stats["Sys"] = float64(memStat.Sys)
+	stats["TotalAlloc"] = float64(memStat.TotalAlloc)
+}
@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const outboxExt = ".json"
+
+var ErrOutboxEntryTooLarge = errors.New("batch is larger than the outbox size limit")
+
+// outboxEntry describes a queued batch file named "<seq>-<unix nano>.json".
+type outboxEntry struct {
+	name      string
+	seq       uint64
+	createdAt time.Time
+	size      int64
+}
+
+// Outbox is a bounded on-disk queue of batches the server did not accept.
+// Every batch is stored in its own file, so a crash loses at most the batch
+// being written. Batches are replayed in the order they were appended; the
+// oldest ones are dropped when the queue exceeds its size or age limit.
+type Outbox struct {
+	dir      string
+	maxSize  int64
+	maxAge   time.Duration
+	entries  []outboxEntry
+	size     int64
+	nextSeq  uint64
+	mu       sync.Mutex
+	replayMu sync.Mutex
+}
+
+// OpenOutbox opens the outbox in dir, creating the directory if needed and
+// picking up batches left by a previous run. Zero limits disable the limit.
+func OpenOutbox(dir string, maxSize int64, maxAge time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Outbox{dir: dir, maxSize: maxSize, maxAge: maxAge}
+
+	for _, file := range files {
+		entry, ok := parseOutboxName(file.Name())
+		if !ok || file.IsDir() {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return nil, err
+		}
+		entry.size = info.Size()
+
+		o.entries = append(o.entries, entry)
+		o.size += entry.size
+		if entry.seq >= o.nextSeq {
+			o.nextSeq = entry.seq + 1
+		}
+	}
+
+	sort.Slice(o.entries, func(i, j int) bool { return o.entries[i].seq < o.entries[j].seq })
+
+	return o, nil
+}
+
+// Len returns the number of queued batches.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.entries)
+}
+
+// Append queues the batch behind the already queued ones, dropping the
+// oldest batches if the size limit would be exceeded.
+func (o *Outbox) Append(metrics []models.Metrics) error {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	if o.maxSize > 0 && int64(len(data)) > o.maxSize {
+		return ErrOutboxEntryTooLarge
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry := outboxEntry{seq: o.nextSeq, createdAt: time.Now(), size: int64(len(data))}
+	entry.name = fmt.Sprintf("%020d-%d%s", entry.seq, entry.createdAt.UnixNano(), outboxExt)
+
+	for o.maxSize > 0 && len(o.entries) > 0 && o.size+entry.size > o.maxSize {
+		logger.Log.Warn("Outbox is full, dropping the oldest batch", zap.String("file", o.entries[0].name))
+		o.removeLocked(o.entries[0])
+	}
+
+	if err := writeFileAtomic(filepath.Join(o.dir, entry.name), data); err != nil {
+		return err
+	}
+
+	o.nextSeq++
+	o.entries = append(o.entries, entry)
+	o.size += entry.size
+
+	return nil
+}
+
+// Replay sends the queued batches in order and removes every batch that
+// was sent. It stops at the first failed send and returns its error, so
+// the remaining batches keep their order for the next replay.
+func (o *Outbox) Replay(send func([]models.Metrics) error) error {
+	o.replayMu.Lock()
+	defer o.replayMu.Unlock()
+
+	for {
+		o.mu.Lock()
+		if len(o.entries) == 0 {
+			o.mu.Unlock()
+			return nil
+		}
+		entry := o.entries[0]
+		if o.maxAge > 0 && time.Since(entry.createdAt) > o.maxAge {
+			logger.Log.Warn("Dropping expired outbox batch", zap.String("file", entry.name))
+			o.removeLocked(entry)
+			o.mu.Unlock()
+			continue
+		}
+		o.mu.Unlock()
+
+		metrics, err := o.read(entry)
+		if err != nil {
+			logger.Log.Error("Dropping unreadable outbox batch", zap.String("file", entry.name), zap.Error(err))
+			o.remove(entry)
+			continue
+		}
+
+		if err := send(metrics); err != nil {
+			return err
+		}
+
+		o.remove(entry)
+	}
+}
+
+func (o *Outbox) read(entry outboxEntry) ([]models.Metrics, error) {
+	data, err := os.ReadFile(filepath.Join(o.dir, entry.name))
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+func (o *Outbox) remove(entry outboxEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.removeLocked(entry)
+}
+
+// removeLocked deletes the entry if it is still queued; the caller must
+// hold the mutex.
+func (o *Outbox) removeLocked(entry outboxEntry) {
+	for i := range o.entries {
+		if o.entries[i].seq != entry.seq {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(o.dir, entry.name)); err != nil && !os.IsNotExist(err) {
+			logger.Log.Error("Failed to remove outbox batch", zap.String("file", entry.name), zap.Error(err))
+		}
+		o.size -= o.entries[i].size
+		o.entries = append(o.entries[:i], o.entries[i+1:]...)
+		return
+	}
+}
+
+func parseOutboxName(name string) (outboxEntry, bool) {
+	base, ok := strings.CutSuffix(name, outboxExt)
+	if !ok {
+		return outboxEntry{}, false
+	}
+
+	seqPart, tsPart, ok := strings.Cut(base, "-")
+	if !ok {
+		return outboxEntry{}, false
+	}
+
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return outboxEntry{}, false
+	}
+	ts, err := strconv.ParseInt(tsPart, 10, 64)
+	if err != nil {
+		return outboxEntry{}, false
+	}
+
+	return outboxEntry{name: name, seq: seq, createdAt: time.Unix(0, ts)}, true
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it, so a
+// crash never leaves a partially written batch behind.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func counterBatch(delta int64) []models.Metrics {
+	return []models.Metrics{{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(delta)}}
+}
+
+func TestOutbox_ReplayInOrderAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	outbox, err := OpenOutbox(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for delta := int64(1); delta <= 3; delta++ {
+		if err := outbox.Append(counterBatch(delta)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	reopened, err := OpenOutbox(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reopened.Len() != 3 {
+		t.Fatalf("expected 3 queued batches, got %d", reopened.Len())
+	}
+
+	errDown := errors.New("down")
+	var sent []int64
+	err = reopened.Replay(func(batch []models.Metrics) error {
+		if len(sent) == 2 {
+			return errDown
+		}
+		sent = append(sent, *batch[0].Delta)
+		return nil
+	})
+	if err != errDown {
+		t.Fatalf("expected replay to stop on send error, got %v", err)
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("expected 1 batch left, got %d", reopened.Len())
+	}
+
+	if err := reopened.Append(counterBatch(4)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = reopened.Replay(func(batch []models.Metrics) error {
+		sent = append(sent, *batch[0].Delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []int64{1, 2, 3, 4}
+	if len(sent) != len(want) {
+		t.Fatalf("expected deltas %v, got %v", want, sent)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("expected deltas %v, got %v", want, sent)
+		}
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		t.Errorf("expected empty outbox directory, got %d files", len(files))
+	}
+}
+
+func TestOutbox_SizeLimitDropsOldest(t *testing.T) {
+	outbox, err := OpenOutbox(t.TempDir(), 100, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for delta := int64(1); delta <= 3; delta++ {
+		if err := outbox.Append(counterBatch(delta)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var sent []int64
+	_ = outbox.Replay(func(batch []models.Metrics) error {
+		sent = append(sent, *batch[0].Delta)
+		return nil
+	})
+	if len(sent) != 2 || sent[0] != 2 || sent[1] != 3 {
+		t.Errorf("expected the oldest batch to be dropped, got %v", sent)
+	}
+
+	if err := outbox.Append(make([]models.Metrics, 10)); err != ErrOutboxEntryTooLarge {
+		t.Errorf("want error %v, got %v", ErrOutboxEntryTooLarge, err)
+	}
+}
+
+func TestOutbox_AgeLimit(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "00000000000000000000-"+strconv.FormatInt(time.Now().Add(-2*time.Hour).UnixNano(), 10)+outboxExt)
+	if err := os.WriteFile(old, []byte(`[{"id":"PollCount","type":"counter","delta":1}]`), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	outbox, err := OpenOutbox(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := outbox.Append(counterBatch(2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var sent []int64
+	_ = outbox.Replay(func(batch []models.Metrics) error {
+		sent = append(sent, *batch[0].Delta)
+		return nil
+	})
+	if len(sent) != 1 || sent[0] != 2 {
+		t.Errorf("expected only the fresh batch, got %v", sent)
+	}
+}
@@ -0,0 +1,318 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProbeCollectorName is the name of the synthetic probe collector.
+const ProbeCollectorName = "probe"
+
+// Probe types.
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+)
+
+// DefaultProbeTimeout is used when a target does not set a timeout.
+const DefaultProbeTimeout = 5 * time.Second
+
+// Gauges reported for every target, labeled with the target name.
+const (
+	ProbeSuccessMetric    = "ProbeSuccess"
+	ProbeLatencyMetric    = "ProbeLatency"
+	ProbeStatusMetric     = "ProbeStatusCode"
+	ProbeCertExpiryMetric = "ProbeTLSCertDaysLeft"
+)
+
+var (
+	ErrEmptyProbeName     = errors.New("probe name is empty")
+	ErrDuplicateProbeName = errors.New("duplicate probe name")
+	ErrInvalidProbeType   = errors.New("probe type must be http or tcp")
+	ErrEmptyProbeTarget   = errors.New("probe needs a url for http or an address for tcp")
+	ErrInvalidProbeCA     = errors.New("probe CA file has no PEM certificates")
+)
+
+// ProbeTargetConfig describes a single checked endpoint.
+type ProbeTargetConfig struct {
+	Name               string            `json:"name"`                 // Unique target name, reported in the "target" label
+	Type               string            `json:"type"`                 // "http" or "tcp"
+	URL                string            `json:"url"`                  // URL requested with GET by http probes
+	Address            string            `json:"address"`              // host:port connected to by tcp probes
+	TLS                bool              `json:"tls"`                  // Whether tcp probes perform a TLS handshake
+	CAFile             string            `json:"ca_file"`              // PEM bundle used instead of the system roots
+	InsecureSkipVerify bool              `json:"insecure_skip_verify"` // Skip certificate verification
+	Timeout            config.Duration   `json:"timeout"`              // Maximum duration of a check
+	Labels             map[string]string `json:"labels"`               // Labels added to the metrics of the target
+}
+
+// probeSettings is the "settings" object of the probe collector.
+type probeSettings struct {
+	Targets []ProbeTargetConfig `json:"targets"`
+}
+
+// ProbeCollector checks endpoints with HTTP GET requests or TCP connects.
+// Every target reports success (0 or 1) and latency in seconds; http
+// targets also report the status code, and TLS targets the days left
+// until the server certificate expires.
+type ProbeCollector struct {
+	targets []ProbeTargetConfig
+	rootCAs map[string]*x509.CertPool
+}
+
+// NewProbeCollector validates the targets and creates a collector for them.
+func NewProbeCollector(targets []ProbeTargetConfig) (*ProbeCollector, error) {
+	names := make(map[string]struct{}, len(targets))
+	rootCAs := map[string]*x509.CertPool{}
+
+	for i, target := range targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("probe #%d: %w", i, ErrEmptyProbeName)
+		}
+		if _, ok := names[target.Name]; ok {
+			return nil, fmt.Errorf("probe %q: %w", target.Name, ErrDuplicateProbeName)
+		}
+		names[target.Name] = struct{}{}
+
+		switch {
+		case target.Type != ProbeHTTP && target.Type != ProbeTCP:
+			return nil, fmt.Errorf("probe %q: %w", target.Name, ErrInvalidProbeType)
+		case target.Type == ProbeHTTP && target.URL == "", target.Type == ProbeTCP && target.Address == "":
+			return nil, fmt.Errorf("probe %q: %w", target.Name, ErrEmptyProbeTarget)
+		}
+
+		if target.CAFile != "" {
+			pem, err := os.ReadFile(target.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("probe %q: %w", target.Name, err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("probe %q: %w", target.Name, ErrInvalidProbeCA)
+			}
+			rootCAs[target.Name] = pool
+		}
+	}
+
+	return &ProbeCollector{targets: targets, rootCAs: rootCAs}, nil
+}
+
+func newProbeCollector(raw json.RawMessage) (Collector, error) {
+	var settings probeSettings
+	if err := decodeSettings(raw, &settings); err != nil {
+		return nil, err
+	}
+
+	return NewProbeCollector(settings.Targets)
+}
+
+func (c *ProbeCollector) Name() string {
+	return ProbeCollectorName
+}
+
+func (c *ProbeCollector) Interval() time.Duration {
+	return 0
+}
+
+// probeResult is the outcome of a single check.
+type probeResult struct {
+	success    bool
+	latency    time.Duration
+	statusCode int
+	certExpiry time.Time
+}
+
+// Collect checks all targets concurrently. A failed check is a measurement,
+// not a collector error, so Collect only fails when the context is done.
+func (c *ProbeCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	results := make([]probeResult, len(c.targets))
+
+	var wg sync.WaitGroup
+	for i, target := range c.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.check(ctx, target)
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	for i, target := range c.targets {
+		result := results[i]
+
+		labels := map[string]string{"target": target.Name}
+		for name, value := range target.Labels {
+			labels[name] = value
+		}
+
+		success := 0.0
+		if result.success {
+			success = 1
+		}
+		metrics = append(metrics,
+			withLabels(gauge(ProbeSuccessMetric, success), labels),
+			withLabels(gauge(ProbeLatencyMetric, result.latency.Seconds()), labels),
+		)
+		if target.Type == ProbeHTTP {
+			metrics = append(metrics, withLabels(gauge(ProbeStatusMetric, float64(result.statusCode)), labels))
+		}
+		if !result.certExpiry.IsZero() {
+			days := time.Until(result.certExpiry).Hours() / 24
+			metrics = append(metrics, withLabels(gauge(ProbeCertExpiryMetric, days), labels))
+		}
+	}
+
+	return metrics, nil
+}
+
+func (c *ProbeCollector) check(ctx context.Context, target ProbeTargetConfig) probeResult {
+	timeout := target.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var expiry atomic.Pointer[time.Time]
+	tlsConfig := probeTLSConfig(c.rootCAs[target.Name], target.InsecureSkipVerify, &expiry)
+
+	start := time.Now()
+	var result probeResult
+	var err error
+	if target.Type == ProbeHTTP {
+		result, err = checkHTTP(ctx, target.URL, tlsConfig)
+	} else {
+		result, err = checkTCP(ctx, target.Address, target.TLS, tlsConfig)
+	}
+	result.latency = time.Since(start)
+	result.success = err == nil
+	if notAfter := expiry.Load(); notAfter != nil {
+		result.certExpiry = *notAfter
+	}
+
+	if err != nil {
+		logger.Log.Debug("Probe failed", zap.String("target", target.Name), zap.Error(err))
+	}
+
+	return result
+}
+
+// checkHTTP requests the URL; responses with a status below 400 succeed.
+func checkHTTP(ctx context.Context, url string, tlsConfig *tls.Config) (probeResult, error) {
+	var result probeResult
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return result, err
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}
+	defer transport.CloseIdleConnections()
+
+	response, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<20))
+
+	result.statusCode = response.StatusCode
+	if response.StatusCode >= 400 {
+		return result, fmt.Errorf("%w: %d", ErrUnexpectedStatus, response.StatusCode)
+	}
+
+	return result, nil
+}
+
+// checkTCP connects to the address and, if requested, completes a TLS handshake.
+func checkTCP(ctx context.Context, address string, useTLS bool, tlsConfig *tls.Config) (probeResult, error) {
+	var result probeResult
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	if !useTLS {
+		return result, nil
+	}
+
+	if tlsConfig.ServerName == "" {
+		host, _, _ := net.SplitHostPort(address)
+		tlsConfig.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// probeTLSConfig returns a TLS config that records when the server
+// certificate chain expires before verifying it, so the expiry is reported
+// for expired and untrusted certificates too.
+func probeTLSConfig(rootCAs *x509.CertPool, skipVerify bool, expiry *atomic.Pointer[time.Time]) *tls.Config {
+	return &tls.Config{
+		// The chain is verified in VerifyConnection, once the expiry is recorded.
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return nil
+			}
+
+			notAfter := certExpiry(state.PeerCertificates)
+			expiry.Store(&notAfter)
+			if skipVerify {
+				return nil
+			}
+
+			intermediates := x509.NewCertPool()
+			for _, cert := range state.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       state.ServerName,
+				Roots:         rootCAs,
+				Intermediates: intermediates,
+			})
+			return err
+		},
+	}
+}
+
+// certExpiry returns the earliest expiry of the certificate chain.
+func certExpiry(certs []*x509.Certificate) time.Time {
+	var earliest time.Time
+	for _, cert := range certs {
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeServerCA(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+// newExpiredTLSServer starts a TLS server whose self-signed certificate
+// expired a day ago, and writes the certificate as a CA file.
+func newExpiredTLSServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "expired"},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(-24 * time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "expired.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return server, path
+}
+
+func TestProbeCollector_ExpiredCertificate(t *testing.T) {
+	server, caFile := newExpiredTLSServer(t)
+
+	collector, err := NewProbeCollector([]ProbeTargetConfig{
+		{Name: "http", Type: ProbeHTTP, URL: server.URL, CAFile: caFile},
+		{Name: "tcp", Type: ProbeTCP, Address: strings.TrimPrefix(server.URL, "https://"), TLS: true, CAFile: caFile},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, target := range []string{"http", "tcp"} {
+		assertGauge(t, metrics, ProbeSuccessMetric, "target", target, 0)
+		days, ok := findMetric(metrics, ProbeCertExpiryMetric, "target", target)
+		if !ok || *days.Value > -0.9 || *days.Value < -1.1 {
+			t.Errorf("%s: expected the certificate to have expired a day ago, got %+v", target, days)
+		}
+	}
+}
+
+func TestProbeCollector_HTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	collector, err := NewProbeCollector([]ProbeTargetConfig{
+		{Name: "up", Type: ProbeHTTP, URL: server.URL + "/health", Labels: map[string]string{"team": "core"}},
+		{Name: "down", Type: ProbeHTTP, URL: server.URL + "/down"},
+		{Name: "tls", Type: ProbeHTTP, URL: tlsServer.URL, CAFile: writeServerCA(t, tlsServer)},
+		{Name: "untrusted", Type: ProbeHTTP, URL: tlsServer.URL},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "up", 1)
+	assertGauge(t, metrics, ProbeStatusMetric, "target", "up", 200)
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "down", 0)
+	assertGauge(t, metrics, ProbeStatusMetric, "target", "down", 503)
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "tls", 1)
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "untrusted", 0)
+	assertGauge(t, metrics, ProbeStatusMetric, "target", "untrusted", 0)
+
+	if up, ok := findMetric(metrics, ProbeSuccessMetric, "team", "core"); !ok || up.Labels["target"] != "up" {
+		t.Errorf("expected target labels, got %+v", up)
+	}
+	if latency, ok := findMetric(metrics, ProbeLatencyMetric, "target", "up"); !ok || *latency.Value <= 0 {
+		t.Errorf("expected positive latency, got %+v", latency)
+	}
+
+	days, ok := findMetric(metrics, ProbeCertExpiryMetric, "target", "tls")
+	want := time.Until(tlsServer.Certificate().NotAfter).Hours() / 24
+	if !ok || *days.Value < want-1 || *days.Value > want+1 {
+		t.Errorf("expected about %.0f days to expiry, got %+v", want, days)
+	}
+	if _, ok := findMetric(metrics, ProbeCertExpiryMetric, "target", "untrusted"); !ok {
+		t.Error("expected certificate expiry for an untrusted certificate")
+	}
+	if _, ok := findMetric(metrics, ProbeCertExpiryMetric, "target", "up"); ok {
+		t.Error("unexpected certificate expiry for a plain http target")
+	}
+}
+
+func TestProbeCollector_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	collector, err := NewProbeCollector([]ProbeTargetConfig{
+		{Name: "open", Type: ProbeTCP, Address: listener.Addr().String()},
+		{Name: "closed", Type: ProbeTCP, Address: closedAddr},
+		{Name: "tls", Type: ProbeTCP, Address: strings.TrimPrefix(tlsServer.URL, "https://"), TLS: true, InsecureSkipVerify: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	metrics, _ := collector.Collect(context.Background())
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "open", 1)
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "closed", 0)
+	assertGauge(t, metrics, ProbeSuccessMetric, "target", "tls", 1)
+
+	if _, ok := findMetric(metrics, ProbeStatusMetric, "target", "open"); ok {
+		t.Error("unexpected status code for a tcp target")
+	}
+	if _, ok := findMetric(metrics, ProbeCertExpiryMetric, "target", "tls"); !ok {
+		t.Error("expected certificate expiry for a tls target")
+	}
+}
+
+func TestNewProbeCollector_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []ProbeTargetConfig
+		wantErr error
+	}{
+		{name: "empty name", targets: []ProbeTargetConfig{{Type: ProbeTCP, Address: "a:1"}}, wantErr: ErrEmptyProbeName},
+		{name: "bad type", targets: []ProbeTargetConfig{{Name: "a", Type: "icmp"}}, wantErr: ErrInvalidProbeType},
+		{name: "no url", targets: []ProbeTargetConfig{{Name: "a", Type: ProbeHTTP}}, wantErr: ErrEmptyProbeTarget},
+		{name: "no address", targets: []ProbeTargetConfig{{Name: "a", Type: ProbeTCP}}, wantErr: ErrEmptyProbeTarget},
+		{name: "duplicate", targets: []ProbeTargetConfig{{Name: "a", Type: ProbeTCP, Address: "a:1"}, {Name: "a", Type: ProbeTCP, Address: "a:1"}}, wantErr: ErrDuplicateProbeName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewProbeCollector(tt.targets); !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcCollectorName is the name of the proc filesystem collector.
+const ProcCollectorName = "proc"
+
+// DefaultProcRoot is the mount point of the proc filesystem.
+const DefaultProcRoot = "/proc"
+
+// diskSectorSize is the unit of the sector counters in /proc/diskstats.
+const diskSectorSize = 512
+
+// meminfoMetrics maps /proc/meminfo fields to metric IDs.
+var meminfoMetrics = map[string]string{
+	"MemTotal":     "MemTotal",
+	"MemFree":      "MemFree",
+	"MemAvailable": "MemAvailable",
+	"Buffers":      "MemBuffers",
+	"Cached":       "MemCached",
+	"SwapTotal":    "SwapTotal",
+	"SwapFree":     "SwapFree",
+}
+
+// cpuTimes holds the busy and total jiffies of a CPU line in /proc/stat.
+type cpuTimes struct {
+	busy  uint64
+	total uint64
+}
+
+// ProcCollector reads host metrics from the proc filesystem: CPU
+// utilization, memory, load averages and network and disk traffic.
+// Counters start at zero when the collector is created, so restarting the
+// agent never reports the traffic since boot twice. Utilization and
+// throughput gauges are computed between two collections and are missing
+// from the first one.
+type ProcCollector struct {
+	root     string
+	now      func() time.Time
+	lastAt   time.Time
+	lastCPU  map[string]cpuTimes
+	lastRate map[string]uint64
+	base     map[string]uint64
+}
+
+// NewProcCollector creates a collector reading the proc filesystem at root.
+func NewProcCollector(root string) *ProcCollector {
+	return &ProcCollector{
+		root:     root,
+		now:      time.Now,
+		lastCPU:  map[string]cpuTimes{},
+		lastRate: map[string]uint64{},
+		base:     map[string]uint64{},
+	}
+}
+
+// procSettings is the "settings" object of the proc collector.
+type procSettings struct {
+	Root string `json:"root"` // Mount point of the proc filesystem
+}
+
+// newProcCollectorFactory creates proc collectors reading root unless the
+// settings name another mount point.
+func newProcCollectorFactory(root string) CollectorFactory {
+	return func(raw json.RawMessage) (Collector, error) {
+		settings := procSettings{Root: root}
+		if err := decodeSettings(raw, &settings); err != nil {
+			return nil, err
+		}
+		if settings.Root == "" {
+			settings.Root = DefaultProcRoot
+		}
+
+		return NewProcCollector(settings.Root), nil
+	}
+}
+
+func (c *ProcCollector) Name() string {
+	return ProcCollectorName
+}
+
+func (c *ProcCollector) Interval() time.Duration {
+	return 0
+}
+
+// Collect reads all proc files. A file that cannot be read or parsed is
+// skipped and reported in the returned error together with the metrics of
+// the other files.
+func (c *ProcCollector) Collect(ctx context.Context) ([]models.Metrics, error) {
+	now := c.now()
+	elapsed := now.Sub(c.lastAt).Seconds()
+	if c.lastAt.IsZero() {
+		elapsed = 0
+	}
+	c.lastAt = now
+
+	var metrics []models.Metrics
+	var errs []error
+
+	readers := []struct {
+		file string
+		read func(*os.File, float64) ([]models.Metrics, error)
+	}{
+		{file: "stat", read: c.readStat},
+		{file: "meminfo", read: c.readMeminfo},
+		{file: "loadavg", read: c.readLoadavg},
+		{file: "net/dev", read: c.readNetDev},
+		{file: "diskstats", read: c.readDiskstats},
+	}
+
+	for _, reader := range readers {
+		if err := ctx.Err(); err != nil {
+			return metrics, err
+		}
+
+		file, err := os.Open(filepath.Join(c.root, reader.file))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		collected, err := reader.read(file, elapsed)
+		file.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", reader.file, err))
+			continue
+		}
+		metrics = append(metrics, collected...)
+	}
+
+	return metrics, errors.Join(errs...)
+}
+
+func (c *ProcCollector) readStat(file *os.File, _ float64) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch name := fields[0]; {
+		case strings.HasPrefix(name, "cpu"):
+			times, err := parseCPUTimes(fields[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			cpu := strings.TrimPrefix(name, "cpu")
+			if cpu == "" {
+				cpu = "total"
+			}
+
+			if last, ok := c.lastCPU[cpu]; ok && times.total > last.total {
+				utilization := 100 * float64(times.busy-last.busy) / float64(times.total-last.total)
+				metrics = append(metrics, gauge("CPUutilization", utilization, "cpu", cpu))
+			}
+			c.lastCPU[cpu] = times
+		case name == "ctxt" || name == "processes":
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			id := map[string]string{"ctxt": "ContextSwitches", "processes": "ProcessesForked"}[name]
+			metrics = append(metrics, c.counter(id, value))
+		case name == "procs_running" || name == "procs_blocked":
+			value, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, err
+			}
+			id := map[string]string{"procs_running": "ProcsRunning", "procs_blocked": "ProcsBlocked"}[name]
+			metrics = append(metrics, gauge(id, value))
+		}
+	}
+
+	return metrics, scanner.Err()
+}
+
+// parseCPUTimes sums the user, nice, system, idle, iowait, irq, softirq and
+// steal jiffies; guest time is already included in user time.
+func parseCPUTimes(fields []string) (cpuTimes, error) {
+	var times cpuTimes
+
+	for i, field := range fields {
+		if i >= 8 {
+			break
+		}
+
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return cpuTimes{}, err
+		}
+
+		times.total += value
+		if i != 3 && i != 4 {
+			times.busy += value
+		}
+	}
+
+	return times, nil
+}
+
+func (c *ProcCollector) readMeminfo(file *os.File, _ float64) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		id, known := meminfoMetrics[name]
+		if !ok || !known {
+			continue
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			value *= 1024
+		}
+
+		metrics = append(metrics, gauge(id, value))
+	}
+
+	return metrics, scanner.Err()
+}
+
+func (c *ProcCollector) readLoadavg(file *os.File, _ float64) ([]models.Metrics, error) {
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return nil, scanner.Err()
+	}
+
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("unexpected format: %q", scanner.Text())
+	}
+
+	metrics := make([]models.Metrics, 0, 3)
+	for i, id := range []string{"Load1", "Load5", "Load15"} {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, gauge(id, value))
+	}
+
+	return metrics, nil
+}
+
+func (c *ProcCollector) readNetDev(file *os.File, elapsed float64) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		iface := strings.TrimSpace(name)
+
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("unexpected format for interface %s", iface)
+		}
+
+		values, err := parseUints(fields, 0, 1, 2, 8, 9, 10)
+		if err != nil {
+			return nil, err
+		}
+
+		metrics = append(metrics,
+			c.counter("NetReceiveBytes", values[0], "interface", iface),
+			c.counter("NetReceivePackets", values[1], "interface", iface),
+			c.counter("NetReceiveErrors", values[2], "interface", iface),
+			c.counter("NetTransmitBytes", values[3], "interface", iface),
+			c.counter("NetTransmitPackets", values[4], "interface", iface),
+			c.counter("NetTransmitErrors", values[5], "interface", iface),
+		)
+		metrics = c.appendRate(metrics, "NetReceiveBytesPerSecond", values[0], elapsed, "interface", iface)
+		metrics = c.appendRate(metrics, "NetTransmitBytesPerSecond", values[3], elapsed, "interface", iface)
+	}
+
+	return metrics, scanner.Err()
+}
+
+func (c *ProcCollector) readDiskstats(file *os.File, elapsed float64) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		device := fields[2]
+		if strings.HasPrefix(device, "loop") || strings.HasPrefix(device, "ram") {
+			continue
+		}
+
+		values, err := parseUints(fields, 3, 5, 7, 9)
+		if err != nil {
+			return nil, err
+		}
+		readBytes := values[1] * diskSectorSize
+		writeBytes := values[3] * diskSectorSize
+
+		metrics = append(metrics,
+			c.counter("DiskReads", values[0], "device", device),
+			c.counter("DiskReadBytes", readBytes, "device", device),
+			c.counter("DiskWrites", values[2], "device", device),
+			c.counter("DiskWriteBytes", writeBytes, "device", device),
+		)
+		metrics = c.appendRate(metrics, "DiskReadBytesPerSecond", readBytes, elapsed, "device", device)
+		metrics = c.appendRate(metrics, "DiskWriteBytesPerSecond", writeBytes, elapsed, "device", device)
+	}
+
+	return metrics, scanner.Err()
+}
+
+// counter returns the increase of a cumulative proc counter since the
+// collector first saw it.
+func (c *ProcCollector) counter(id string, value uint64, labels ...string) models.Metrics {
+	metric := models.Metrics{ID: id, MType: models.CounterMetric, Labels: labelPairs(labels)}
+	key := metric.ID + "\x00" + models.LabelsKey(metric.Labels)
+
+	base, ok := c.base[key]
+	if !ok || value < base {
+		base = value
+		c.base[key] = base
+	}
+
+	delta := int64(value - base)
+	metric.Delta = &delta
+	return metric
+}
+
+// appendRate appends the per-second rate of a cumulative value since the
+// previous collection, if there was one.
+func (c *ProcCollector) appendRate(metrics []models.Metrics, id string, value uint64, elapsed float64, labels ...string) []models.Metrics {
+	key := id + "\x00" + strings.Join(labels, "\x00")
+
+	last, ok := c.lastRate[key]
+	c.lastRate[key] = value
+	if !ok || elapsed <= 0 || value < last {
+		return metrics
+	}
+
+	return append(metrics, gauge(id, float64(value-last)/elapsed, labels...))
+}
+
+func gauge(id string, value float64, labels ...string) models.Metrics {
+	return models.Metrics{ID: id, MType: models.GaugeMetric, Value: &value, Labels: labelPairs(labels)}
+}
+
+func labelPairs(pairs []string) map[string]string {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels[pairs[i]] = pairs[i+1]
+	}
+
+	return labels
+}
+
+func parseUints(fields []string, indexes ...int) ([]uint64, error) {
+	values := make([]uint64, len(indexes))
+
+	for i, index := range indexes {
+		value, err := strconv.ParseUint(fields[index], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
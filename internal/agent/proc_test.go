@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"alerting-service/internal/models"
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// findMetric returns the metric with the ID and, if given, the label value.
+func findMetric(metrics []models.Metrics, id, label, value string) (models.Metrics, bool) {
+	for _, metric := range metrics {
+		if metric.ID == id && (label == "" || metric.Labels[label] == value) {
+			return metric, true
+		}
+	}
+	return models.Metrics{}, false
+}
+
+func assertGauge(t *testing.T, metrics []models.Metrics, id, label, value string, want float64) {
+	t.Helper()
+
+	metric, ok := findMetric(metrics, id, label, value)
+	if !ok || metric.Value == nil {
+		t.Errorf("gauge %s{%s=%q} not found", id, label, value)
+		return
+	}
+	if math.Abs(*metric.Value-want) > 1e-9 {
+		t.Errorf("gauge %s{%s=%q} = %v, want %v", id, label, value, *metric.Value, want)
+	}
+}
+
+func assertCounter(t *testing.T, metrics []models.Metrics, id, label, value string, want int64) {
+	t.Helper()
+
+	metric, ok := findMetric(metrics, id, label, value)
+	if !ok || metric.Delta == nil {
+		t.Errorf("counter %s{%s=%q} not found", id, label, value)
+		return
+	}
+	if *metric.Delta != want {
+		t.Errorf("counter %s{%s=%q} = %d, want %d", id, label, value, *metric.Delta, want)
+	}
+}
+
+func TestProcCollector(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	collector := NewProcCollector(filepath.Join("testdata", "proc", "first"))
+	collector.now = func() time.Time { return now }
+
+	first, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertGauge(t, first, "MemTotal", "", "", 8000000*1024)
+	assertGauge(t, first, "MemAvailable", "", "", 5000000*1024)
+	assertGauge(t, first, "SwapFree", "", "", 900000*1024)
+	assertGauge(t, first, "Load1", "", "", 0.5)
+	assertGauge(t, first, "Load15", "", "", 0.1)
+	assertGauge(t, first, "ProcsRunning", "", "", 2)
+	assertCounter(t, first, "NetReceiveBytes", "interface", "eth0", 0)
+	assertCounter(t, first, "ContextSwitches", "", "", 0)
+	if _, ok := findMetric(first, "CPUutilization", "", ""); ok {
+		t.Error("expected no CPU utilization on the first collection")
+	}
+	if _, ok := findMetric(first, "DiskReads", "device", "loop0"); ok {
+		t.Error("expected loop devices to be skipped")
+	}
+
+	now = now.Add(10 * time.Second)
+	collector.root = filepath.Join("testdata", "proc", "second")
+
+	second, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assertGauge(t, second, "CPUutilization", "cpu", "total", 50)
+	assertGauge(t, second, "CPUutilization", "cpu", "0", 75)
+	assertGauge(t, second, "CPUutilization", "cpu", "1", 25)
+	assertGauge(t, second, "Load1", "", "", 1.5)
+	assertCounter(t, second, "ContextSwitches", "", "", 600)
+	assertCounter(t, second, "ProcessesForked", "", "", 10)
+	assertCounter(t, second, "NetReceiveBytes", "interface", "eth0", 20000)
+	assertCounter(t, second, "NetTransmitErrors", "interface", "eth0", 2)
+	assertGauge(t, second, "NetReceiveBytesPerSecond", "interface", "eth0", 2000)
+	assertGauge(t, second, "NetTransmitBytesPerSecond", "interface", "lo", 0)
+	assertCounter(t, second, "DiskReads", "device", "sda", 10)
+	assertCounter(t, second, "DiskWriteBytes", "device", "sda", 4000*diskSectorSize)
+	assertGauge(t, second, "DiskReadBytesPerSecond", "device", "sda", 2000*diskSectorSize/10)
+}
+
+func TestProcCollector_MissingFiles(t *testing.T) {
+	collector := NewProcCollector(t.TempDir())
+
+	metrics, err := collector.Collect(context.Background())
+	if err == nil {
+		t.Error("expected an error for missing proc files")
+	}
+	if len(metrics) != 0 {
+		t.Errorf("expected no metrics, got %d", len(metrics))
+	}
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/crypto"
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var ErrInvalidInterval = errors.New("report and poll intervals must be positive")
+
+// runner holds the state of a running agent that a config reload changes.
+// It is owned by the report goroutine.
+type runner struct {
+	conf         *config.Config
+	registry     *Registry
+	workers      *atomic.Pointer[sentMetricWorker]
+	store        *collectorStore
+	telemetry    *Telemetry
+	labels       map[string]string
+	collectors   *collectorGroup
+	reportTicker *time.Ticker
+}
+
+// report queues the metrics collected since the previous report.
+func (r *runner) report(batchesChan chan<- []models.Metrics) {
+	w := r.workers.Load()
+	r.telemetry.Reported()
+	sendMetrics(w.counters.Deltas(r.store.Metrics(r.labels)), r.conf.BatchSize, batchesChan)
+}
+
+// reload applies the updated configuration. It is validated as a whole
+// before anything is applied; options that are only read on start are
+// reported and keep their current values.
+func (r *runner) reload(ctx context.Context, updated *config.Config) error {
+	if updated.ReportInterval <= 0 || updated.PollInterval <= 0 {
+		return ErrInvalidInterval
+	}
+
+	level, err := zapcore.ParseLevel(updated.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := crypto.LoadPublicKey(updated.CryptoKey)
+	if err != nil {
+		return err
+	}
+
+	collectorsChanged := updated.PollInterval != r.conf.PollInterval ||
+		!slices.Equal(updated.EnabledCollectors, r.conf.EnabledCollectors) ||
+		!reflect.DeepEqual(updated.Collectors, r.conf.Collectors)
+
+	var collectors []Collector
+	if collectorsChanged {
+		collectors, err = r.registry.Build(updated.Collectors, updated.EnabledCollectors)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fields := restartRequired(r.conf, updated); len(fields) > 0 {
+		logger.Log.Warn("Config changes require a restart and were not applied", zap.Strings("fields", fields))
+		updated.RateLimit = r.conf.RateLimit
+		updated.OutboxDir = r.conf.OutboxDir
+		updated.OutboxMaxSize = r.conf.OutboxMaxSize
+		updated.OutboxMaxAge = r.conf.OutboxMaxAge
+		updated.ProcRoot = r.conf.ProcRoot
+		updated.MetricsAddr = r.conf.MetricsAddr
+		updated.TLS = r.conf.TLS
+		updated.TLSCA = r.conf.TLSCA
+		updated.TLSCert = r.conf.TLSCert
+		updated.TLSKey = r.conf.TLSKey
+		updated.TLSServerName = r.conf.TLSServerName
+	}
+
+	worker := *r.workers.Load()
+	worker.conf = updated
+	worker.publicKey = publicKey
+	worker.retry = NewRetryPolicy(updated.RetryAttempts, updated.RetryMinDelay, updated.RetryMaxDelay)
+	if updated.RunAddr != r.conf.RunAddr {
+		worker.realIP = outboundAddr(updated.RunAddr)
+	}
+	r.workers.Store(&worker)
+
+	if updated.ReportInterval != r.conf.ReportInterval {
+		r.reportTicker.Reset(time.Duration(updated.ReportInterval) * time.Second)
+		r.telemetry.SetStaleAfter(HealthStaleReports * time.Duration(updated.ReportInterval) * time.Second)
+	}
+
+	if updated.RunAddr != r.conf.RunAddr || !maps.Equal(updated.Labels, r.conf.Labels) {
+		r.labels = hostLabels(updated.RunAddr, updated.Labels)
+	}
+
+	if collectorsChanged {
+		r.collectors.stop()
+		r.store.retain(collectors)
+		r.collectors = startCollectors(ctx, collectors, time.Duration(updated.PollInterval)*time.Second, r.store, r.telemetry)
+	}
+
+	r.conf = updated
+
+	logger.Log.Info("Config reloaded",
+		zap.String("log_level", level.String()),
+		zap.Int("report_interval", updated.ReportInterval),
+		zap.Int("poll_interval", updated.PollInterval),
+		zap.Bool("collectors_restarted", collectorsChanged),
+		zap.Any("labels", r.labels))
+
+	logger.SetLevel(level)
+	return nil
+}
+
+// restartRequired returns the options that changed but are only read on
+// start.
+func restartRequired(previous, updated *config.Config) []string {
+	var fields []string
+	if previous.RateLimit != updated.RateLimit {
+		fields = append(fields, "rate_limit")
+	}
+	if previous.OutboxDir != updated.OutboxDir {
+		fields = append(fields, "outbox_dir")
+	}
+	if previous.OutboxMaxSize != updated.OutboxMaxSize {
+		fields = append(fields, "outbox_max_size")
+	}
+	if previous.OutboxMaxAge != updated.OutboxMaxAge {
+		fields = append(fields, "outbox_max_age")
+	}
+	if previous.ProcRoot != updated.ProcRoot {
+		fields = append(fields, "proc_root")
+	}
+	if previous.MetricsAddr != updated.MetricsAddr {
+		fields = append(fields, "metrics_address")
+	}
+	if previous.TLS != updated.TLS || previous.TLSCA != updated.TLSCA || previous.TLSCert != updated.TLSCert ||
+		previous.TLSKey != updated.TLSKey || previous.TLSServerName != updated.TLSServerName {
+		fields = append(fields, "tls")
+	}
+
+	return fields
+}
@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRunner(t *testing.T, conf *config.Config, registry *Registry) *runner {
+	t.Helper()
+
+	collectors, err := registry.Build(conf.Collectors, conf.EnabledCollectors)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	workers := &atomic.Pointer[sentMetricWorker]{}
+	workers.Store(&sentMetricWorker{conf: conf, counters: newCounterTracker()})
+
+	store := newCollectorStore()
+	r := &runner{
+		conf:         conf,
+		registry:     registry,
+		workers:      workers,
+		store:        store,
+		collectors:   startCollectors(context.Background(), collectors, time.Hour, store, nil),
+		reportTicker: time.NewTicker(time.Hour),
+	}
+	t.Cleanup(func() {
+		r.collectors.stop()
+		r.reportTicker.Stop()
+	})
+
+	return r
+}
+
+// metricRegistry registers collectors reporting a gauge named after them.
+func metricRegistry() *Registry {
+	registry := NewRegistry()
+	for _, name := range []string{"first", "second"} {
+		registry.Register(name, name == "first", func(json.RawMessage) (Collector, error) {
+			return &staticCollector{name: name, metrics: []models.Metrics{gauge(name, 1)}}, nil
+		})
+	}
+	return registry
+}
+
+// waitForMetrics waits until the store holds exactly the metrics with the IDs.
+func waitForMetrics(t *testing.T, store *collectorStore, ids ...string) {
+	t.Helper()
+
+	var got []string
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		got = got[:0]
+		for _, metric := range store.Metrics(nil) {
+			got = append(got, metric.ID)
+		}
+		if reflect.DeepEqual(got, ids) {
+			return
+		}
+	}
+	t.Fatalf("expected metrics %v, got %v", ids, got)
+}
+
+func TestRunner_Reload(t *testing.T) {
+	conf := &config.Config{RunAddr: "localhost:8080", ReportInterval: 10, PollInterval: 2, RateLimit: 3, HashKey: "old"}
+	r := newTestRunner(t, conf, metricRegistry())
+	waitForMetrics(t, r.store, "first")
+
+	updated := *conf
+	updated.HashKey = "new"
+	updated.RateLimit = 5
+	updated.Labels = map[string]string{"env": "prod"}
+	updated.EnabledCollectors = []string{"second"}
+
+	if err := r.reload(context.Background(), &updated); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if w := r.workers.Load(); w.conf.HashKey != "new" || w.counters == nil {
+		t.Errorf("workers not updated: %+v", w)
+	}
+	if r.conf.RateLimit != 3 {
+		t.Errorf("RateLimit = %d; want the value read on start", r.conf.RateLimit)
+	}
+	if r.labels["env"] != "prod" {
+		t.Errorf("labels not updated: %v", r.labels)
+	}
+	waitForMetrics(t, r.store, "second")
+}
+
+func TestRunner_ReloadInvalid(t *testing.T) {
+	conf := &config.Config{ReportInterval: 10, PollInterval: 2, HashKey: "old"}
+	r := newTestRunner(t, conf, metricRegistry())
+
+	tests := []struct {
+		name    string
+		update  func(*config.Config)
+		wantErr error
+	}{
+		{name: "unknown collector", update: func(c *config.Config) { c.EnabledCollectors = []string{"missing"} }, wantErr: ErrUnknownCollector},
+		{name: "zero interval", update: func(c *config.Config) { c.ReportInterval = 0 }, wantErr: ErrInvalidInterval},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := *conf
+			updated.HashKey = "new"
+			tt.update(&updated)
+
+			if err := r.reload(context.Background(), &updated); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if r.conf != conf || r.workers.Load().conf.HashKey != "old" {
+				t.Error("invalid config partially applied")
+			}
+		})
+	}
+}
+
+func Test_restartRequired(t *testing.T) {
+	previous := &config.Config{RateLimit: 3, OutboxDir: "./outbox", MetricsAddr: ""}
+	updated := &config.Config{RateLimit: 3, OutboxDir: "/var/outbox", MetricsAddr: "localhost:9100"}
+
+	if fields := restartRequired(previous, updated); !reflect.DeepEqual(fields, []string{"outbox_dir", "metrics_address"}) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Default retry settings used when the configuration does not set them.
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryMinDelay = time.Second
+	DefaultRetryMaxDelay = 10 * time.Second
+)
+
+// SendError describes a batch the server did not accept.
+type SendError struct {
+	StatusCode int    // HTTP response status, 0 if no response was received
+	Body       string // Beginning of the response body
+	Attempts   int    // Number of attempts made
+	Metrics    int    // Number of metrics in the batch
+	Err        error  // Underlying transport or status error
+}
+
+func (e *SendError) Error() string {
+	if e.StatusCode >= 200 && e.StatusCode < 300 {
+		return fmt.Sprintf("send %d metrics: server responded %d after %d attempt(s): %v", e.Metrics, e.StatusCode, e.Attempts, e.Err)
+	}
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("send %d metrics: server responded %d %q after %d attempt(s)", e.Metrics, e.StatusCode, e.Body, e.Attempts)
+	}
+	return fmt.Sprintf("send %d metrics after %d attempt(s): %v", e.Metrics, e.Attempts, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether sending the batch again may succeed: transport
+// errors and 5xx responses are retryable, 4xx responses and successful
+// responses with an invalid signature are not.
+func (e *SendError) Retryable() bool {
+	return e.StatusCode == 0 || e.StatusCode >= 500
+}
+
+// Delivered reports whether the server accepted the batch even though the
+// send failed, as when the response signature does not match.
+func (e *SendError) Delivered() bool {
+	return e.StatusCode >= 200 && e.StatusCode < 300
+}
+
+// isDelivered reports whether err is a send error for a batch the server
+// accepted.
+func isDelivered(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Delivered()
+}
+
+// isRetryable reports whether err is a retryable send error.
+func isRetryable(err error) bool {
+	var sendErr *SendError
+	return errors.As(err, &sendErr) && sendErr.Retryable()
+}
+
+// RetryPolicy retries retryable send errors with jittered exponential backoff.
+type RetryPolicy struct {
+	Attempts int           // Total number of attempts, including the first one
+	MinDelay time.Duration // Delay before the first retry
+	MaxDelay time.Duration // Upper bound of a single delay
+}
+
+// NewRetryPolicy creates a policy, replacing non-positive values with defaults.
+func NewRetryPolicy(attempts int, minDelay, maxDelay time.Duration) RetryPolicy {
+	policy := RetryPolicy{Attempts: attempts, MinDelay: minDelay, MaxDelay: maxDelay}
+
+	if policy.Attempts <= 0 {
+		policy.Attempts = DefaultRetryAttempts
+	}
+	if policy.MinDelay <= 0 {
+		policy.MinDelay = DefaultRetryMinDelay
+	}
+	if policy.MaxDelay < policy.MinDelay {
+		policy.MaxDelay = max(DefaultRetryMaxDelay, policy.MinDelay)
+	}
+
+	return policy
+}
+
+// Do calls send until it succeeds, returns a non-retryable error, the
+// attempts are exhausted or the context is cancelled. The attempt count is
+// recorded in the returned SendError.
+func (p RetryPolicy) Do(ctx context.Context, send func() error) error {
+	attempts := max(p.Attempts, 1)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(p.Delay(attempt - 1)):
+			}
+		}
+
+		err = send()
+
+		var sendErr *SendError
+		if errors.As(err, &sendErr) {
+			sendErr.Attempts = attempt
+		}
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Delay returns the delay before the given retry, counting from 1: the
+// minimum delay doubled on every retry, capped at the maximum delay, of
+// which a random half is dropped so agents do not retry in lockstep.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.MinDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
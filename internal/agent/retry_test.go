@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := NewRetryPolicy(5, 100*time.Millisecond, 300*time.Millisecond)
+
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{retry: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{retry: 2, min: 100 * time.Millisecond, max: 200 * time.Millisecond},
+		{retry: 3, min: 150 * time.Millisecond, max: 300 * time.Millisecond},
+		{retry: 10, min: 150 * time.Millisecond, max: 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if delay := policy.Delay(tt.retry); delay < tt.min || delay > tt.max {
+				t.Fatalf("retry %d: delay %v not in [%v, %v]", tt.retry, delay, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestNewRetryPolicy_Defaults(t *testing.T) {
+	policy := NewRetryPolicy(0, 0, 0)
+
+	if policy.Attempts != DefaultRetryAttempts || policy.MinDelay != DefaultRetryMinDelay || policy.MaxDelay != DefaultRetryMaxDelay {
+		t.Errorf("unexpected defaults: %+v", policy)
+	}
+}
+
+func TestSendBatch_RetryByStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{name: "success", statuses: []int{200}, wantRequests: 1},
+		{name: "5xx then success", statuses: []int{500, 503, 200}, wantRequests: 3},
+		{name: "5xx exhausts attempts", statuses: []int{500, 502, 503, 200}, wantRequests: 3, wantStatus: 503, wantErr: true},
+		{name: "4xx is not retried", statuses: []int{400, 200}, wantRequests: 1, wantStatus: 400, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[requests]
+				requests++
+				if status != http.StatusOK {
+					http.Error(w, "invalid hash", status)
+				}
+			}))
+			defer server.Close()
+
+			worker := sentMetricWorker{
+				client: server.Client(),
+				conf:   &config.Config{RunAddr: strings.TrimPrefix(server.URL, "http://")},
+				retry:  NewRetryPolicy(3, time.Millisecond, 2*time.Millisecond),
+			}
+			batch := []models.Metrics{{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(1)}}
+
+			err := worker.sendBatch(context.Background(), batch)
+			if requests != tt.wantRequests {
+				t.Errorf("expected %d requests, got %d", tt.wantRequests, requests)
+			}
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var sendErr *SendError
+			if !errors.As(err, &sendErr) {
+				t.Fatalf("expected SendError, got %v", err)
+			}
+			if sendErr.StatusCode != tt.wantStatus || sendErr.Attempts != tt.wantRequests || sendErr.Body != "invalid hash" {
+				t.Errorf("unexpected error: %+v", sendErr)
+			}
+			if !errors.Is(err, ErrUnexpectedStatus) {
+				t.Errorf("expected %v, got %v", ErrUnexpectedStatus, err)
+			}
+		})
+	}
+}
+
+func TestSendBatch_RetryTransportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	worker := sentMetricWorker{
+		client: http.DefaultClient,
+		conf:   &config.Config{RunAddr: addr},
+		retry:  NewRetryPolicy(2, time.Millisecond, time.Millisecond),
+	}
+
+	err := worker.sendBatch(context.Background(), []models.Metrics{{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1)}})
+
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) || !sendErr.Retryable() || sendErr.Attempts != 2 {
+		t.Fatalf("expected retryable SendError after 2 attempts, got %v", err)
+	}
+	if !errors.Is(err, ErrServerUnreachable) {
+		t.Errorf("expected %v, got %v", ErrServerUnreachable, err)
+	}
+}
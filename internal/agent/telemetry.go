@@ -0,0 +1,268 @@
+package agent
+
+import (
+	"alerting-service/internal/exposition"
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HealthStaleReports is the number of report intervals without a
+// successful send after which the agent is reported unhealthy.
+const HealthStaleReports = 3
+
+// collectorStats holds the telemetry of a single collector.
+type collectorStats struct {
+	duration time.Duration
+	errors   int64
+	lastErr  error
+}
+
+// Telemetry records what the agent itself is doing. All methods are safe
+// for concurrent use and do nothing on a nil receiver.
+type Telemetry struct {
+	sendsAttempted atomic.Int64
+	sendsSucceeded atomic.Int64
+	sendsFailed    atomic.Int64
+	bytesSent      atomic.Int64
+	lastReport     atomic.Int64 // Unix nanoseconds
+	lastSuccess    atomic.Int64 // Unix nanoseconds
+	started        time.Time
+	staleAfter     atomic.Int64 // Nanoseconds, zero disables the check
+	queueLength    func() int
+	outboxLength   func() int
+	collectors     map[string]*collectorStats
+	mu             sync.Mutex
+}
+
+// NewTelemetry creates the agent telemetry; queueLength and outboxLength
+// report the current queue sizes and may be nil.
+func NewTelemetry(queueLength, outboxLength func() int) *Telemetry {
+	return &Telemetry{
+		started:      time.Now(),
+		queueLength:  queueLength,
+		outboxLength: outboxLength,
+		collectors:   map[string]*collectorStats{},
+	}
+}
+
+// SetStaleAfter sets how long the agent may go without a successful send
+// before /healthz reports it unhealthy. Zero disables the check.
+func (t *Telemetry) SetStaleAfter(d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.staleAfter.Store(int64(d))
+}
+
+// SendAttempted records a request carrying the given number of payload bytes.
+func (t *Telemetry) SendAttempted(bytes int) {
+	if t == nil {
+		return
+	}
+	t.sendsAttempted.Add(1)
+	t.bytesSent.Add(int64(bytes))
+}
+
+// SendFinished records the outcome of a request.
+func (t *Telemetry) SendFinished(err error) {
+	if t == nil {
+		return
+	}
+	if err != nil {
+		t.sendsFailed.Add(1)
+		return
+	}
+	t.sendsSucceeded.Add(1)
+	t.lastSuccess.Store(time.Now().UnixNano())
+}
+
+// Reported records the start of a report cycle.
+func (t *Telemetry) Reported() {
+	if t == nil {
+		return
+	}
+	t.lastReport.Store(time.Now().UnixNano())
+}
+
+// Collected records the duration and outcome of a collection.
+func (t *Telemetry) Collected(name string, duration time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.collectors[name]
+	if !ok {
+		stats = &collectorStats{}
+		t.collectors[name] = stats
+	}
+	stats.duration = duration
+	stats.lastErr = err
+	if err != nil {
+		stats.errors++
+	}
+}
+
+// unhealthy returns why the agent is unhealthy, or an empty string: no
+// successful send within the stale threshold, counted from the start until
+// the first one, or a collector whose last collection failed.
+func (t *Telemetry) unhealthy() string {
+	if staleAfter := time.Duration(t.staleAfter.Load()); staleAfter > 0 {
+		since := t.started
+		if last := t.lastSuccess.Load(); last != 0 {
+			since = time.Unix(0, last)
+		}
+		if age := time.Since(since); age > staleAfter {
+			return fmt.Sprintf("no successful send for %s", age.Round(time.Second))
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.collectors))
+	for name, stats := range t.collectors {
+		if stats.lastErr != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("collector %s failed: %v", names[0], t.collectors[names[0]].lastErr)
+}
+
+// Metrics returns the telemetry as metrics. Timestamps are Unix seconds and
+// are zero until the first report or successful send.
+func (t *Telemetry) Metrics() []models.Metrics {
+	metrics := []models.Metrics{
+		telemetryCounter("AgentSendsAttempted", t.sendsAttempted.Load()),
+		telemetryCounter("AgentSendsSucceeded", t.sendsSucceeded.Load()),
+		telemetryCounter("AgentSendsFailed", t.sendsFailed.Load()),
+		telemetryCounter("AgentBytesSent", t.bytesSent.Load()),
+		gauge("AgentLastReportTimestamp", unixSeconds(t.lastReport.Load())),
+		gauge("AgentLastSuccessTimestamp", unixSeconds(t.lastSuccess.Load())),
+	}
+	if t.queueLength != nil {
+		metrics = append(metrics, gauge("AgentQueueLength", float64(t.queueLength())))
+	}
+	if t.outboxLength != nil {
+		metrics = append(metrics, gauge("AgentOutboxLength", float64(t.outboxLength())))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.collectors))
+	for name := range t.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stats := t.collectors[name]
+		metrics = append(metrics,
+			gauge("AgentCollectorDuration", stats.duration.Seconds(), "collector", name),
+			telemetryCounter("AgentCollectorErrors", stats.errors, "collector", name),
+		)
+	}
+
+	return metrics
+}
+
+// Health is the body of the agent health endpoint.
+type Health struct {
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	QueueLength int        `json:"queue_length"`
+	LastReport  *time.Time `json:"last_report,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// Handler serves /healthz and /metrics with the agent telemetry. /healthz
+// answers 503 with the reason while the agent is unhealthy.
+func (t *Telemetry) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		health := Health{
+			Status:      "ok",
+			LastReport:  timestamp(t.lastReport.Load()),
+			LastSuccess: timestamp(t.lastSuccess.Load()),
+		}
+		if t.queueLength != nil {
+			health.QueueLength = t.queueLength()
+		}
+
+		status := http.StatusOK
+		if health.Reason = t.unhealthy(); health.Reason != "" {
+			health.Status = "unhealthy"
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(health); err != nil {
+			logger.Log.Error("Failed to write health response", zap.Error(err))
+		}
+	})
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", exposition.ContentType)
+		if err := exposition.WriteText(w, t.Metrics()); err != nil {
+			logger.Log.Error("Failed to write agent metrics", zap.Error(err))
+		}
+	})
+
+	return mux
+}
+
+// Serve serves the telemetry on addr until the context is cancelled.
+func (t *Telemetry) Serve(ctx context.Context, addr string) {
+	server := &http.Server{Addr: addr, Handler: t.Handler(), ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Log.Info("Serving agent telemetry", zap.String("address", addr))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Log.Error("Agent telemetry server failed", zap.Error(err))
+	}
+}
+
+func telemetryCounter(id string, value int64, labels ...string) models.Metrics {
+	return models.Metrics{ID: id, MType: models.CounterMetric, Delta: &value, Labels: labelPairs(labels)}
+}
+
+func unixSeconds(nanos int64) float64 {
+	if nanos == 0 {
+		return 0
+	}
+	return float64(nanos) / float64(time.Second)
+}
+
+func timestamp(nanos int64) *time.Time {
+	if nanos == 0 {
+		return nil
+	}
+	ts := time.Unix(0, nanos)
+	return &ts
+}
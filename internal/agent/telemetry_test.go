@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/exposition"
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelemetry_Metrics(t *testing.T) {
+	telemetry := NewTelemetry(func() int { return 2 }, func() int { return 5 })
+
+	telemetry.SendAttempted(100)
+	telemetry.SendFinished(nil)
+	telemetry.SendAttempted(50)
+	telemetry.SendFinished(errors.New("failed"))
+	telemetry.Collected("proc", 250*time.Millisecond, nil)
+	telemetry.Collected("proc", 500*time.Millisecond, errors.New("failed"))
+
+	metrics := telemetry.Metrics()
+
+	assertCounter(t, metrics, "AgentSendsAttempted", "", "", 2)
+	assertCounter(t, metrics, "AgentSendsSucceeded", "", "", 1)
+	assertCounter(t, metrics, "AgentSendsFailed", "", "", 1)
+	assertCounter(t, metrics, "AgentBytesSent", "", "", 150)
+	assertGauge(t, metrics, "AgentQueueLength", "", "", 2)
+	assertGauge(t, metrics, "AgentOutboxLength", "", "", 5)
+	assertGauge(t, metrics, "AgentCollectorDuration", "collector", "proc", 0.5)
+	assertCounter(t, metrics, "AgentCollectorErrors", "collector", "proc", 1)
+	assertGauge(t, metrics, "AgentLastReportTimestamp", "", "", 0)
+
+	if metric, _ := findMetric(metrics, "AgentLastSuccessTimestamp", "", ""); metric.Value == nil || *metric.Value == 0 {
+		t.Errorf("expected last success timestamp to be set, got %+v", metric)
+	}
+}
+
+func TestTelemetry_Nil(t *testing.T) {
+	var telemetry *Telemetry
+
+	telemetry.SendAttempted(1)
+	telemetry.SendFinished(nil)
+	telemetry.Reported()
+	telemetry.Collected("runtime", time.Second, nil)
+}
+
+func TestTelemetry_Handler(t *testing.T) {
+	telemetry := NewTelemetry(func() int { return 3 }, nil)
+	telemetry.Reported()
+
+	server := httptest.NewServer(telemetry.Handler())
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+
+	var health Health
+	if err := json.NewDecoder(response.Body).Decode(&health); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusOK || health.Status != "ok" || health.QueueLength != 3 {
+		t.Errorf("unexpected health %d %+v", response.StatusCode, health)
+	}
+	if health.LastReport == nil || health.LastSuccess != nil {
+		t.Errorf("unexpected timestamps %+v", health)
+	}
+
+	response, err = server.Client().Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Header.Get("Content-Type") != exposition.ContentType {
+		t.Errorf("unexpected content type %q", response.Header.Get("Content-Type"))
+	}
+	if !strings.Contains(string(body), "AgentQueueLength 3") {
+		t.Errorf("queue length not exposed:\n%s", body)
+	}
+}
+
+func TestTelemetry_Unhealthy(t *testing.T) {
+	getHealth := func(t *testing.T, telemetry *Telemetry) (int, Health) {
+		t.Helper()
+
+		recorder := httptest.NewRecorder()
+		telemetry.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		var health Health
+		if err := json.NewDecoder(recorder.Body).Decode(&health); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return recorder.Code, health
+	}
+
+	t.Run("stale send", func(t *testing.T) {
+		telemetry := NewTelemetry(nil, nil)
+		telemetry.SetStaleAfter(time.Minute)
+		telemetry.lastSuccess.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+
+		code, health := getHealth(t, telemetry)
+		if code != http.StatusServiceUnavailable || health.Status != "unhealthy" || !strings.Contains(health.Reason, "no successful send") {
+			t.Errorf("unexpected health %d %+v", code, health)
+		}
+
+		telemetry.SendFinished(nil)
+		if code, health := getHealth(t, telemetry); code != http.StatusOK || health.Reason != "" {
+			t.Errorf("unexpected health after a send %d %+v", code, health)
+		}
+	})
+
+	t.Run("failed collection", func(t *testing.T) {
+		telemetry := NewTelemetry(nil, nil)
+		telemetry.Collected("proc", time.Second, errors.New("permission denied"))
+
+		code, health := getHealth(t, telemetry)
+		if code != http.StatusServiceUnavailable || !strings.Contains(health.Reason, "collector proc failed: permission denied") {
+			t.Errorf("unexpected health %d %+v", code, health)
+		}
+
+		telemetry.Collected("proc", time.Second, nil)
+		if code, health := getHealth(t, telemetry); code != http.StatusOK || health.Status != "ok" {
+			t.Errorf("unexpected health after a collection %d %+v", code, health)
+		}
+	})
+}
+
+func TestTelemetry_CountsSends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	telemetry := NewTelemetry(nil, nil)
+	worker := sentMetricWorker{
+		client:    server.Client(),
+		conf:      &config.Config{RunAddr: strings.TrimPrefix(server.URL, "http://")},
+		telemetry: telemetry,
+	}
+	batch := []models.Metrics{{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1)}}
+
+	if err := worker.sendBatch(context.Background(), batch); err == nil {
+		t.Fatal("expected error")
+	}
+
+	metrics := telemetry.Metrics()
+	assertCounter(t, metrics, "AgentSendsAttempted", "", "", 1)
+	assertCounter(t, metrics, "AgentSendsFailed", "", "", 1)
+	assertCounter(t, metrics, "AgentSendsSucceeded", "", "", 0)
+}
@@ -0,0 +1,222 @@
+package alerting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"alerting-service/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Delivery statuses recorded in the delivery log.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryExpired   = "expired"
+)
+
+// compactMinRecords is the number of obsolete records that makes Pending
+// rewrite the log with only the pending deliveries.
+const compactMinRecords = 100
+
+// Delivery is a single notification addressed to a single webhook.
+type Delivery struct {
+	ID        string    `json:"id"`                   // Unique delivery identifier
+	Webhook   int       `json:"webhook"`              // Index of the webhook in the notification config
+	URL       string    `json:"url"`                  // Webhook URL
+	Payload   Payload   `json:"payload"`              // Notification body
+	Status    string    `json:"status"`               // "pending", "delivered" or "expired"
+	Attempts  int       `json:"attempts"`             // Number of attempts made so far
+	LastError string    `json:"last_error,omitempty"` // Error of the last failed attempt
+	CreatedAt time.Time `json:"created_at"`           // Time the notification was queued
+	UpdatedAt time.Time `json:"updated_at"`           // Time of the last status change
+}
+
+// DeliveryLog is a JSON lines file with the history of webhook deliveries.
+// The latest record of a delivery wins; records of finished deliveries are
+// dropped when the log is compacted.
+type DeliveryLog struct {
+	filename string
+	file     *os.File
+	encoder  *json.Encoder
+	mutex    sync.Mutex
+}
+
+// NewDeliveryLog opens or creates the delivery log file. A partial last
+// record left by a crash is truncated.
+func NewDeliveryLog(filename string) (*DeliveryLog, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	_, size, err := readDeliveries(file)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &DeliveryLog{
+		filename: filename,
+		file:     file,
+		encoder:  json.NewEncoder(file),
+	}, nil
+}
+
+// Record appends the current state of a delivery to the log. A record that
+// fails to be written is truncated so that it does not corrupt the next one.
+func (l *DeliveryLog) Record(delivery Delivery) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	size, err := l.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	delivery.UpdatedAt = time.Now()
+	if err := l.encoder.Encode(&delivery); err != nil {
+		return errors.Join(err, l.file.Truncate(size))
+	}
+	return nil
+}
+
+// Pending returns the deliveries whose latest record is pending, in the
+// order they were first logged. The log is compacted once most of its
+// records are obsolete.
+func (l *DeliveryLog) Pending() ([]Delivery, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	records, _, err := readDeliveries(l.file)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]Delivery{}
+	var order []string
+	for _, delivery := range records {
+		if _, ok := latest[delivery.ID]; !ok {
+			order = append(order, delivery.ID)
+		}
+		latest[delivery.ID] = delivery
+	}
+
+	var pending []Delivery
+	for _, id := range order {
+		if latest[id].Status == DeliveryPending {
+			pending = append(pending, latest[id])
+		}
+	}
+
+	if obsolete := len(records) - len(pending); obsolete >= compactMinRecords && obsolete >= len(pending) {
+		if err := l.compact(pending); err != nil {
+			logger.Log.Error("Failed to compact webhook delivery log", zap.Error(err))
+		}
+	}
+
+	return pending, nil
+}
+
+// compact replaces the log with the pending deliveries.
+func (l *DeliveryLog) compact(pending []Delivery) error {
+	tmpName := l.filename + ".tmp"
+	tmp, err := os.OpenFile(tmpName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(tmp)
+	for i := range pending {
+		if err = encoder.Encode(&pending[i]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, l.filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	file, err := os.OpenFile(l.filename, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	l.file.Close()
+	l.file = file
+	l.encoder = json.NewEncoder(file)
+	return nil
+}
+
+// readDeliveries reads every record of the log and returns the size of the
+// complete records. A last line without a newline is a partial record left
+// by a crash and is not returned; corrupt lines are skipped.
+func readDeliveries(file *os.File) ([]Delivery, int64, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	var records []Delivery
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				logger.Log.Warn("Ignoring partial record at the end of the webhook delivery log")
+			}
+			return records, size, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		size += int64(len(line))
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var delivery Delivery
+		if err := json.Unmarshal(line, &delivery); err != nil {
+			logger.Log.Warn("Skipping corrupt record in the webhook delivery log", zap.Error(err))
+			continue
+		}
+		records = append(records, delivery)
+	}
+}
+
+// Close closes the underlying file.
+func (l *DeliveryLog) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	return l.file.Close()
+}
@@ -0,0 +1,107 @@
+package alerting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryLog_Pending(t *testing.T) {
+	deliveryLog, err := NewDeliveryLog(filepath.Join(t.TempDir(), "deliveries.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	records := []Delivery{
+		{ID: "1", Status: DeliveryPending},
+		{ID: "2", Status: DeliveryPending},
+		{ID: "1", Status: DeliveryDelivered, Attempts: 1},
+		{ID: "3", Status: DeliveryPending},
+		{ID: "2", Status: DeliveryPending, Attempts: 3, LastError: "timeout"},
+	}
+	for _, record := range records {
+		if err := deliveryLog.Record(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err := deliveryLog.Pending()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending deliveries, got %d", len(pending))
+	}
+	if pending[0].ID != "2" || pending[0].Attempts != 3 || pending[0].LastError != "timeout" {
+		t.Errorf("unexpected first pending delivery: %+v", pending[0])
+	}
+	if pending[1].ID != "3" {
+		t.Errorf("unexpected second pending delivery: %+v", pending[1])
+	}
+}
+
+func TestDeliveryLog_PartialRecord(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "deliveries.log")
+	content := `{"id":"1","status":"pending"}` + "\n" + `not json` + "\n" + `{"id":"2","status":"pend`
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveryLog, err := NewDeliveryLog(filename)
+	if err != nil {
+		t.Fatalf("expected a partial last record to be tolerated, got %v", err)
+	}
+	defer deliveryLog.Close()
+
+	if err := deliveryLog.Record(Delivery{ID: "3", Status: DeliveryPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := deliveryLog.Pending()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != "1" || pending[1].ID != "3" {
+		t.Errorf("expected deliveries 1 and 3 to be pending, got %+v", pending)
+	}
+}
+
+func TestDeliveryLog_Compact(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "deliveries.log")
+	deliveryLog, err := NewDeliveryLog(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	for i := 0; i < compactMinRecords; i++ {
+		id := fmt.Sprint(i)
+		_ = deliveryLog.Record(Delivery{ID: id, Status: DeliveryPending})
+		_ = deliveryLog.Record(Delivery{ID: id, Status: DeliveryDelivered, Attempts: 1})
+	}
+	_ = deliveryLog.Record(Delivery{ID: "kept", Status: DeliveryPending})
+
+	pending, err := deliveryLog.Pending()
+	if err != nil || len(pending) != 1 {
+		t.Fatalf("expected one pending delivery, got %+v, %v", pending, err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("expected the log to be compacted to 1 record, got %d", lines)
+	}
+
+	if err := deliveryLog.Record(Delivery{ID: "kept", Status: DeliveryDelivered}); err != nil {
+		t.Fatalf("expected the compacted log to be writable, got %v", err)
+	}
+	if pending, _ := deliveryLog.Pending(); len(pending) != 0 {
+		t.Errorf("expected no pending deliveries, got %+v", pending)
+	}
+}
@@ -0,0 +1,248 @@
+package alerting
+
+import (
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"alerting-service/internal/repository"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// State is the lifecycle state of an alert.
+type State string
+
+const (
+	StateInactive State = "inactive" // Condition does not hold
+	StatePending  State = "pending"  // Condition holds, waiting for the rule "for" duration
+	StateFiring   State = "firing"   // Condition has held for at least the rule "for" duration
+)
+
+// Alert is the evaluation state of a rule for a single series.
+type Alert struct {
+	Rule       Rule              // Rule the alert belongs to
+	Labels     map[string]string // Labels of the evaluated series
+	State      State             // Current state
+	Value      float64           // Last observed metric value
+	ActiveAt   time.Time         // When the condition started to hold
+	FiredAt    time.Time         // When the alert started firing
+	ResolvedAt time.Time         // When a firing alert was resolved
+}
+
+// Resolved reports whether the alert has just stopped firing.
+func (a Alert) Resolved() bool {
+	return a.State == StateInactive && !a.ResolvedAt.IsZero()
+}
+
+// Notifier is informed when an alert starts firing or gets resolved.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Engine periodically evaluates alert rules against the metric storage.
+type Engine struct {
+	storageRepository repository.StorageRepository
+	notifiers         []Notifier
+	rules             []Rule
+	alerts            map[string]*Alert
+	intervals         chan time.Duration
+	mu                sync.Mutex
+}
+
+// NewEngine creates an engine for the rules; alerts are created as matching
+// series appear in the storage.
+func NewEngine(storageRepository repository.StorageRepository, rules []Rule, notifiers ...Notifier) *Engine {
+	return &Engine{
+		storageRepository: storageRepository,
+		notifiers:         notifiers,
+		rules:             rules,
+		alerts:            map[string]*Alert{},
+		intervals:         make(chan time.Duration, 1),
+	}
+}
+
+// Update replaces the rules and the evaluation interval of a running engine.
+// Alerts of removed rules are dropped without a notification; alerts of
+// changed rules are re-evaluated against the new condition.
+func (e *Engine) Update(rules []Rule, interval time.Duration) {
+	e.mu.Lock()
+	e.rules = rules
+
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		names[rule.Name] = struct{}{}
+	}
+	for key, alert := range e.alerts {
+		if _, ok := names[alert.Rule.Name]; !ok {
+			delete(e.alerts, key)
+		}
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-e.intervals:
+	default:
+	}
+	e.intervals <- interval
+}
+
+// Run evaluates the rules every interval until the context is cancelled
+// and passes firing and resolved alerts to the notifiers.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case interval := <-e.intervals:
+			ticker.Reset(interval)
+		case now := <-ticker.C:
+			for _, alert := range e.Evaluate(now) {
+				if alert.State == StateFiring || alert.Resolved() {
+					e.notify(ctx, alert)
+				}
+			}
+		}
+	}
+}
+
+func (e *Engine) notify(ctx context.Context, alert Alert) {
+	for _, notifier := range e.notifiers {
+		if err := notifier.Notify(ctx, alert); err != nil {
+			logger.Log.Error("Failed to notify about alert", zap.String("rule", alert.Rule.Name), zap.Error(err))
+		}
+	}
+}
+
+// Evaluate checks every rule once and returns the alerts whose state changed.
+// A rule produces a separate alert for every series matching its metric ID,
+// type and labels; alerts of series that disappeared are resolved.
+func (e *Engine) Evaluate(now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changed []Alert
+
+	for _, rule := range e.rules {
+		series, err := e.storageRepository.FindMetrics(models.MetricFilter{
+			ID:     rule.MetricID,
+			MType:  rule.MetricType,
+			Labels: rule.Labels,
+		})
+		if err != nil {
+			logger.Log.Error("Failed to read metrics for alert rule", zap.String("rule", rule.Name), zap.Error(err))
+			continue
+		}
+		sort.Slice(series, func(i, j int) bool {
+			return models.LabelsKey(series[i].Labels) < models.LabelsKey(series[j].Labels)
+		})
+
+		seen := make(map[string]struct{}, len(series))
+		for _, metric := range series {
+			key := alertKey(rule.Name, metric.Labels)
+			seen[key] = struct{}{}
+
+			alert, ok := e.alerts[key]
+			if !ok {
+				alert = &Alert{Rule: rule, Labels: metric.Labels, State: StateInactive}
+				e.alerts[key] = alert
+			}
+			alert.Rule = rule
+
+			value, ok := metricValue(metric)
+			if ok {
+				alert.Value = value
+			}
+			if alert.transition(now, ok && rule.Matches(value)) {
+				changed = append(changed, *alert)
+			}
+		}
+
+		for key, alert := range e.alerts {
+			if _, ok := seen[key]; ok || alert.Rule.Name != rule.Name {
+				continue
+			}
+			if alert.transition(now, false) {
+				changed = append(changed, *alert)
+			}
+			delete(e.alerts, key)
+		}
+	}
+
+	return changed
+}
+
+// transition moves the alert to the next state and reports whether the
+// state changed.
+func (a *Alert) transition(now time.Time, active bool) bool {
+	previous := a.State
+
+	if active {
+		if a.State == StateInactive {
+			a.State = StatePending
+			a.ActiveAt = now
+			a.FiredAt = time.Time{}
+			a.ResolvedAt = time.Time{}
+		}
+		if a.State == StatePending && now.Sub(a.ActiveAt) >= a.Rule.For.Duration() {
+			a.State = StateFiring
+			a.FiredAt = now
+		}
+	} else if a.State != StateInactive {
+		if a.State == StateFiring {
+			a.ResolvedAt = now
+		}
+		a.State = StateInactive
+	}
+
+	if a.State == previous {
+		return false
+	}
+
+	logger.Log.Info("Alert state changed",
+		zap.String("rule", a.Rule.Name),
+		zap.Any("labels", a.Labels),
+		zap.String("from", string(previous)),
+		zap.String("to", string(a.State)),
+		zap.Float64("value", a.Value))
+	return true
+}
+
+// Alerts returns a snapshot of all alerts sorted by rule name and labels.
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule.Name != alerts[j].Rule.Name {
+			return alerts[i].Rule.Name < alerts[j].Rule.Name
+		}
+		return models.LabelsKey(alerts[i].Labels) < models.LabelsKey(alerts[j].Labels)
+	})
+
+	return alerts
+}
+
+func alertKey(rule string, labels map[string]string) string {
+	return rule + "\x00" + models.LabelsKey(labels)
+}
+
+func metricValue(metric models.Metrics) (float64, bool) {
+	switch {
+	case metric.MType == models.CounterMetric && metric.Delta != nil:
+		return float64(*metric.Delta), true
+	case metric.MType == models.GaugeMetric && metric.Value != nil:
+		return *metric.Value, true
+	}
+
+	return 0, false
+}
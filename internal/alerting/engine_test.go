@@ -0,0 +1,119 @@
+package alerting
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/models"
+	"alerting-service/internal/repository"
+	"alerting-service/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestEngine_PendingThenFiring(t *testing.T) {
+	storage := repository.NewMemStorageRepository()
+	rule := Rule{Name: "HighHeap", MetricID: "HeapAlloc", MetricType: "gauge", Operator: ">", Threshold: 100, For: config.Duration(time.Minute)}
+	engine := NewEngine(storage, []Rule{rule})
+
+	start := time.Now()
+
+	_ = storage.UpdateGaugeMetric("HeapAlloc", 150)
+	changed := engine.Evaluate(start)
+	if len(changed) != 1 || changed[0].State != StatePending {
+		t.Fatalf("expected transition to pending, got %+v", changed)
+	}
+
+	changed = engine.Evaluate(start.Add(30 * time.Second))
+	if len(changed) != 0 {
+		t.Fatalf("expected no transition before for duration, got %+v", changed)
+	}
+
+	changed = engine.Evaluate(start.Add(time.Minute))
+	if len(changed) != 1 || changed[0].State != StateFiring {
+		t.Fatalf("expected transition to firing, got %+v", changed)
+	}
+	if !changed[0].ActiveAt.Equal(start) {
+		t.Errorf("expected active since %s, got %s", start, changed[0].ActiveAt)
+	}
+
+	_ = storage.UpdateGaugeMetric("HeapAlloc", 50)
+	changed = engine.Evaluate(start.Add(2 * time.Minute))
+	if len(changed) != 1 || changed[0].State != StateInactive || changed[0].ResolvedAt.IsZero() {
+		t.Fatalf("expected resolved alert, got %+v", changed)
+	}
+}
+
+func TestEngine_FiresImmediatelyWithoutFor(t *testing.T) {
+	storage := repository.NewMemStorageRepository()
+	rule := Rule{Name: "Polls", MetricID: "PollCount", MetricType: "counter", Operator: ">=", Threshold: 5}
+	engine := NewEngine(storage, []Rule{rule})
+
+	_ = storage.UpdateCounterMetric("PollCount", 5)
+	changed := engine.Evaluate(time.Now())
+	if len(changed) != 1 || changed[0].State != StateFiring {
+		t.Fatalf("expected transition to firing, got %+v", changed)
+	}
+	if changed[0].Value != 5 {
+		t.Errorf("expected value 5, got %v", changed[0].Value)
+	}
+}
+
+func TestEngine_MissingMetricHasNoAlerts(t *testing.T) {
+	storage := repository.NewMemStorageRepository()
+	rule := Rule{Name: "Missing", MetricID: "Unknown", MetricType: "gauge", Operator: "<", Threshold: 1}
+	engine := NewEngine(storage, []Rule{rule})
+
+	if changed := engine.Evaluate(time.Now()); len(changed) != 0 {
+		t.Fatalf("expected no transitions, got %+v", changed)
+	}
+
+	if alerts := engine.Alerts(); len(alerts) != 0 {
+		t.Errorf("expected no alerts, got %+v", alerts)
+	}
+}
+
+func TestEngine_AlertPerLabeledSeries(t *testing.T) {
+	storage := repository.NewMemStorageRepository()
+	rule := Rule{Name: "HighAlloc", MetricID: "Alloc", MetricType: "gauge", Labels: map[string]string{"env": "prod"}, Operator: ">", Threshold: 100}
+	engine := NewEngine(storage, []Rule{rule})
+
+	_ = storage.UpdateMetrics([]models.Metrics{
+		{ID: "Alloc", MType: "gauge", Value: utils.FloatPtr(150), Labels: map[string]string{"env": "prod", "host": "a"}},
+		{ID: "Alloc", MType: "gauge", Value: utils.FloatPtr(50), Labels: map[string]string{"env": "prod", "host": "b"}},
+		{ID: "Alloc", MType: "gauge", Value: utils.FloatPtr(500), Labels: map[string]string{"env": "dev", "host": "c"}},
+	})
+
+	changed := engine.Evaluate(time.Now())
+	if len(changed) != 1 || changed[0].State != StateFiring || changed[0].Labels["host"] != "a" {
+		t.Fatalf("expected host a to fire, got %+v", changed)
+	}
+
+	if alerts := engine.Alerts(); len(alerts) != 2 {
+		t.Errorf("expected alerts for 2 prod series, got %+v", alerts)
+	}
+}
+
+func TestEngine_Update(t *testing.T) {
+	storage := repository.NewMemStorageRepository()
+	heap := Rule{Name: "HighHeap", MetricID: "HeapAlloc", MetricType: "gauge", Operator: ">", Threshold: 100}
+	polls := Rule{Name: "Polls", MetricID: "PollCount", MetricType: "counter", Operator: ">=", Threshold: 5}
+	engine := NewEngine(storage, []Rule{heap, polls})
+
+	_ = storage.UpdateGaugeMetric("HeapAlloc", 150)
+	_ = storage.UpdateCounterMetric("PollCount", 5)
+	if changed := engine.Evaluate(time.Now()); len(changed) != 2 {
+		t.Fatalf("expected two firing alerts, got %+v", changed)
+	}
+
+	heap.Threshold = 200
+	engine.Update([]Rule{heap}, time.Second)
+
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].Rule.Name != "HighHeap" {
+		t.Fatalf("expected alerts of removed rules to be dropped, got %+v", alerts)
+	}
+
+	changed := engine.Evaluate(time.Now())
+	if len(changed) != 1 || !changed[0].Resolved() || changed[0].Rule.Threshold != 200 {
+		t.Fatalf("expected alert resolved by the new threshold, got %+v", changed)
+	}
+}
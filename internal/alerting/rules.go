@@ -0,0 +1,149 @@
+package alerting
+
+import (
+	"alerting-service/internal/config"
+	"alerting-service/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Comparison operators supported by alert rules.
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// DefaultEvaluationInterval is used when the rules file does not set one.
+const DefaultEvaluationInterval = 10 * time.Second
+
+var (
+	ErrEmptyRuleName     = errors.New("alert rule name is empty")
+	ErrDuplicateRuleName = errors.New("duplicate alert rule name")
+	ErrEmptyRuleMetric   = errors.New("alert rule metric id is empty")
+	ErrInvalidRuleType   = errors.New("alert rule metric type must be gauge or counter")
+	ErrInvalidOperator   = errors.New("alert rule operator must be one of >, >=, <, <=, ==, !=")
+	ErrNegativeFor       = errors.New("alert rule for duration must not be negative")
+	ErrEmptyWebhookURL   = errors.New("webhook url is empty")
+)
+
+// Rule describes a threshold condition on the series of a stored metric.
+type Rule struct {
+	Name       string            `json:"name"`        // Unique rule name
+	MetricID   string            `json:"metric_id"`   // Metric identifier to evaluate
+	MetricType string            `json:"metric_type"` // Metric type: "gauge" or "counter"
+	Labels     map[string]string `json:"labels"`      // Optional label values a series must have
+	Operator   string            `json:"operator"`    // Comparison operator
+	Threshold  float64           `json:"threshold"`   // Value the metric is compared against
+	For        config.Duration   `json:"for"`         // How long the condition must hold before firing
+}
+
+// Config is the content of the alert rules file.
+type Config struct {
+	EvaluationInterval config.Duration    `json:"evaluation_interval"`
+	Rules              []Rule             `json:"rules"`
+	Notifications      NotificationConfig `json:"notifications"`
+}
+
+// NotificationConfig configures webhook delivery of alert state changes.
+type NotificationConfig struct {
+	Webhooks           []WebhookConfig `json:"webhooks"`            // Receivers of every notification
+	Retries            int             `json:"retries"`             // Attempts per delivery pass
+	Backoff            config.Duration `json:"backoff"`             // Delay before the first retry, doubled on each retry
+	RedeliveryInterval config.Duration `json:"redelivery_interval"` // How often failed deliveries are retried again
+	DeliveryLog        string          `json:"delivery_log"`        // Path to the persistent delivery log
+	MaxAttempts        int             `json:"max_attempts"`        // Attempts after which a delivery is given up
+	MaxAge             config.Duration `json:"max_age"`             // Age after which a pending delivery is given up
+}
+
+// LoadConfig reads and validates the alert rules file.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.EvaluationInterval <= 0 {
+		cfg.EvaluationInterval = config.Duration(DefaultEvaluationInterval)
+	}
+
+	if err := ValidateRules(cfg.Rules); err != nil {
+		return nil, err
+	}
+
+	for i, webhook := range cfg.Notifications.Webhooks {
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("webhook #%d: %w", i, ErrEmptyWebhookURL)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// ValidateRules checks every rule and makes sure rule names are unique.
+func ValidateRules(rules []Rule) error {
+	names := make(map[string]struct{}, len(rules))
+
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule #%d: %w", i, err)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("rule %q: %w", rule.Name, ErrDuplicateRuleName)
+		}
+		names[rule.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// Validate checks that the rule can be evaluated.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return ErrEmptyRuleName
+	}
+	if r.MetricID == "" {
+		return ErrEmptyRuleMetric
+	}
+	if r.MetricType != models.GaugeMetric && r.MetricType != models.CounterMetric {
+		return ErrInvalidRuleType
+	}
+	if _, ok := comparators[r.Operator]; !ok {
+		return ErrInvalidOperator
+	}
+	if r.For < 0 {
+		return ErrNegativeFor
+	}
+
+	return nil
+}
+
+// Matches reports whether the value satisfies the rule condition.
+func (r Rule) Matches(value float64) bool {
+	compare, ok := comparators[r.Operator]
+	if !ok {
+		return false
+	}
+
+	return compare(value, r.Threshold)
+}
+
+var comparators = map[string]func(value, threshold float64) bool{
+	OpGreater:      func(value, threshold float64) bool { return value > threshold },
+	OpGreaterEqual: func(value, threshold float64) bool { return value >= threshold },
+	OpLess:         func(value, threshold float64) bool { return value < threshold },
+	OpLessEqual:    func(value, threshold float64) bool { return value <= threshold },
+	OpEqual:        func(value, threshold float64) bool { return value == threshold },
+	OpNotEqual:     func(value, threshold float64) bool { return value != threshold },
+}
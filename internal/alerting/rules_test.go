@@ -0,0 +1,101 @@
+package alerting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	data := `{
+		"evaluation_interval": "5s",
+		"rules": [
+			{"name": "HighHeap", "metric_id": "HeapAlloc", "metric_type": "gauge", "operator": ">", "threshold": 100, "for": "1m"}
+		]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.EvaluationInterval.Duration() != 5*time.Second {
+		t.Errorf("expected evaluation interval 5s, got %s", cfg.EvaluationInterval.Duration())
+	}
+	if len(cfg.Rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(cfg.Rules))
+	}
+	if cfg.Rules[0].For.Duration() != time.Minute {
+		t.Errorf("expected for 1m, got %s", cfg.Rules[0].For.Duration())
+	}
+}
+
+func TestLoadConfig_DefaultInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"rules": []}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.EvaluationInterval.Duration() != DefaultEvaluationInterval {
+		t.Errorf("expected default interval, got %s", cfg.EvaluationInterval.Duration())
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	valid := Rule{Name: "r", MetricID: "m", MetricType: "gauge", Operator: ">"}
+
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr error
+	}{
+		{name: "valid rule", rules: []Rule{valid}, wantErr: nil},
+		{name: "empty name", rules: []Rule{{MetricID: "m", MetricType: "gauge", Operator: ">"}}, wantErr: ErrEmptyRuleName},
+		{name: "empty metric", rules: []Rule{{Name: "r", MetricType: "gauge", Operator: ">"}}, wantErr: ErrEmptyRuleMetric},
+		{name: "invalid type", rules: []Rule{{Name: "r", MetricID: "m", MetricType: "histogram", Operator: ">"}}, wantErr: ErrInvalidRuleType},
+		{name: "invalid operator", rules: []Rule{{Name: "r", MetricID: "m", MetricType: "gauge", Operator: "=>"}}, wantErr: ErrInvalidOperator},
+		{name: "duplicate name", rules: []Rule{valid, valid}, wantErr: ErrDuplicateRuleName},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateRules(test.rules)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("want error: %v, got: %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		operator string
+		value    float64
+		want     bool
+	}{
+		{operator: OpGreater, value: 11, want: true},
+		{operator: OpGreater, value: 10, want: false},
+		{operator: OpGreaterEqual, value: 10, want: true},
+		{operator: OpLess, value: 9, want: true},
+		{operator: OpLessEqual, value: 11, want: false},
+		{operator: OpEqual, value: 10, want: true},
+		{operator: OpNotEqual, value: 10, want: false},
+	}
+
+	for _, test := range tests {
+		rule := Rule{Operator: test.operator, Threshold: 10}
+		if got := rule.Matches(test.value); got != test.want {
+			t.Errorf("%v %s 10: want %v, got %v", test.value, test.operator, test.want, got)
+		}
+	}
+}
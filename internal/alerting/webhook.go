@@ -0,0 +1,384 @@
+package alerting
+
+import (
+	"alerting-service/internal/logger"
+	sign "alerting-service/internal/signature"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Default delivery settings used when the rules file does not set them.
+const (
+	DefaultWebhookRetries     = 3
+	DefaultWebhookBackoff     = time.Second
+	DefaultRedeliveryInterval = time.Minute
+	DefaultMaxAttempts        = 30
+	DefaultMaxAge             = 24 * time.Hour
+)
+
+// Payload states sent to webhooks.
+const (
+	PayloadFiring   = "firing"
+	PayloadResolved = "resolved"
+)
+
+// Payload is the JSON body posted to webhooks on alert state changes.
+type Payload struct {
+	Alert     string            `json:"alert"`             // Rule name
+	MetricID  string            `json:"metric_id"`         // Metric identifier
+	Labels    map[string]string `json:"labels,omitempty"`  // Labels of the series
+	Value     float64           `json:"value"`             // Last observed metric value
+	Threshold float64           `json:"threshold"`         // Rule threshold
+	State     string            `json:"state"`             // "firing" or "resolved"
+	StartsAt  time.Time         `json:"starts_at"`         // When the condition started to hold
+	EndsAt    *time.Time        `json:"ends_at,omitempty"` // When the alert was resolved
+}
+
+// NewPayload builds a webhook payload from an alert.
+func NewPayload(alert Alert) Payload {
+	payload := Payload{
+		Alert:     alert.Rule.Name,
+		MetricID:  alert.Rule.MetricID,
+		Labels:    alert.Labels,
+		Value:     alert.Value,
+		Threshold: alert.Rule.Threshold,
+		State:     PayloadFiring,
+		StartsAt:  alert.ActiveAt,
+	}
+
+	if alert.Resolved() {
+		resolvedAt := alert.ResolvedAt
+		payload.State = PayloadResolved
+		payload.EndsAt = &resolvedAt
+	}
+
+	return payload
+}
+
+// WebhookConfig describes a single webhook receiver.
+type WebhookConfig struct {
+	URL    string `json:"url"`    // Receiver URL
+	Secret string `json:"secret"` // Optional key for the HashSHA256 signature header
+}
+
+// webhookQueueSize is the number of deliveries waiting for each webhook.
+const webhookQueueSize = 100
+
+// WebhookNotifier posts alert notifications to webhooks. Every webhook has
+// its own queue and worker, so a slow receiver does not hold up the others.
+// Deliveries are retried with exponential backoff; deliveries that still
+// fail, or do not fit in a full queue, are kept in the delivery log and
+// retried on the next redelivery pass, until they run out of attempts or
+// get too old.
+type WebhookNotifier struct {
+	client             *http.Client
+	webhooks           []WebhookConfig
+	retries            int
+	backoff            time.Duration
+	redeliveryInterval time.Duration
+	maxAttempts        int
+	maxAge             time.Duration
+	deliveryLog        *DeliveryLog
+	queues             []chan Delivery
+
+	mutex  sync.Mutex
+	queued map[string]bool // Deliveries that are queued or being delivered
+}
+
+// NewWebhookNotifier creates a notifier for the configured webhooks.
+// The delivery log may be nil, in which case deliveries that exhaust their
+// retries or find the queue full are dropped.
+func NewWebhookNotifier(client *http.Client, cfg NotificationConfig, deliveryLog *DeliveryLog) *WebhookNotifier {
+	notifier := &WebhookNotifier{
+		client:             client,
+		webhooks:           cfg.Webhooks,
+		retries:            cfg.Retries,
+		backoff:            cfg.Backoff.Duration(),
+		redeliveryInterval: cfg.RedeliveryInterval.Duration(),
+		maxAttempts:        cfg.MaxAttempts,
+		maxAge:             cfg.MaxAge.Duration(),
+		deliveryLog:        deliveryLog,
+		queues:             make([]chan Delivery, len(cfg.Webhooks)),
+		queued:             map[string]bool{},
+	}
+
+	for i := range notifier.queues {
+		notifier.queues[i] = make(chan Delivery, webhookQueueSize)
+	}
+
+	if notifier.retries <= 0 {
+		notifier.retries = DefaultWebhookRetries
+	}
+	if notifier.backoff <= 0 {
+		notifier.backoff = DefaultWebhookBackoff
+	}
+	if notifier.redeliveryInterval <= 0 {
+		notifier.redeliveryInterval = DefaultRedeliveryInterval
+	}
+	if notifier.maxAttempts <= 0 {
+		notifier.maxAttempts = DefaultMaxAttempts
+	}
+	if notifier.maxAge <= 0 {
+		notifier.maxAge = DefaultMaxAge
+	}
+
+	return notifier
+}
+
+// Notify queues a delivery of the alert to every webhook. It never waits
+// for a receiver: a delivery that does not fit in the queue of its webhook
+// is left pending in the delivery log for the next redelivery pass.
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	payload := NewPayload(alert)
+	now := time.Now()
+
+	for i, webhook := range n.webhooks {
+		delivery := Delivery{
+			ID:        newDeliveryID(),
+			Webhook:   i,
+			URL:       webhook.URL,
+			Payload:   payload,
+			Status:    DeliveryPending,
+			CreatedAt: now,
+		}
+
+		if err := n.deliveryLog.Record(delivery); err != nil {
+			logger.Log.Error("Failed to record webhook delivery", zap.Error(err))
+		}
+
+		n.mutex.Lock()
+		queued := n.enqueue(delivery)
+		n.mutex.Unlock()
+
+		if !queued {
+			if n.deliveryLog == nil {
+				logger.Log.Error("Dropping webhook delivery, the queue is full",
+					zap.String("url", delivery.URL),
+					zap.String("alert", delivery.Payload.Alert))
+				continue
+			}
+			logger.Log.Warn("Webhook queue is full, leaving the delivery for redelivery",
+				zap.String("url", delivery.URL),
+				zap.String("alert", delivery.Payload.Alert))
+		}
+	}
+
+	return nil
+}
+
+// Run starts a worker per webhook and delivers queued notifications until
+// the context is cancelled. Pending deliveries from the log are queued
+// again on start and then every redelivery interval.
+func (n *WebhookNotifier) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, queue := range n.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.work(ctx, queue)
+		}()
+	}
+	defer wg.Wait()
+
+	ticker := time.NewTicker(n.redeliveryInterval)
+	defer ticker.Stop()
+
+	n.redeliver()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.redeliver()
+		}
+	}
+}
+
+// work delivers the deliveries of a single webhook one at a time.
+func (n *WebhookNotifier) work(ctx context.Context, queue <-chan Delivery) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case delivery := <-queue:
+			n.finish(n.deliver(ctx, delivery))
+		}
+	}
+}
+
+// redeliver queues the pending deliveries from the log that are not queued
+// or being delivered already.
+func (n *WebhookNotifier) redeliver() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	pending, err := n.deliveryLog.Pending()
+	if err != nil {
+		logger.Log.Error("Failed to read webhook delivery log", zap.Error(err))
+		return
+	}
+
+	for _, delivery := range pending {
+		if n.queued[delivery.ID] {
+			continue
+		}
+		if _, ok := n.webhook(delivery); !ok {
+			delivery.LastError = "webhook is no longer configured"
+			n.expire(delivery)
+			continue
+		}
+		if n.expired(delivery) {
+			n.expire(delivery)
+			continue
+		}
+		n.enqueue(delivery)
+	}
+}
+
+// enqueue hands the delivery to the worker of its webhook unless it is
+// queued already. It reports false if the queue is full. The caller must
+// hold the mutex.
+func (n *WebhookNotifier) enqueue(delivery Delivery) bool {
+	if n.queued[delivery.ID] {
+		return true
+	}
+
+	select {
+	case n.queues[delivery.Webhook] <- delivery:
+		n.queued[delivery.ID] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// finish records the outcome of a delivery attempt. The record and the
+// release of the delivery happen under the mutex so that redeliver does
+// not queue a delivery that has just been delivered.
+func (n *WebhookNotifier) finish(delivery Delivery) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if delivery.Status == DeliveryExpired {
+		n.expire(delivery)
+	} else if err := n.deliveryLog.Record(delivery); err != nil {
+		logger.Log.Error("Failed to record webhook delivery", zap.Error(err))
+	}
+	delete(n.queued, delivery.ID)
+}
+
+// deliver posts the delivery with retries and returns its new state.
+func (n *WebhookNotifier) deliver(ctx context.Context, delivery Delivery) Delivery {
+	delay := n.backoff
+
+	for attempt := 0; attempt < n.retries && delivery.Attempts < n.maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return delivery
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		delivery.Attempts++
+		err := n.post(ctx, delivery)
+		if err == nil {
+			delivery.Status = DeliveryDelivered
+			delivery.LastError = ""
+			return delivery
+		}
+
+		delivery.LastError = err.Error()
+		logger.Log.Warn("Webhook delivery failed",
+			zap.String("url", delivery.URL),
+			zap.String("alert", delivery.Payload.Alert),
+			zap.Int("attempt", delivery.Attempts),
+			zap.Error(err))
+	}
+
+	if n.expired(delivery) {
+		delivery.Status = DeliveryExpired
+	}
+	return delivery
+}
+
+// expired reports whether the delivery ran out of attempts or is too old to
+// be retried again.
+func (n *WebhookNotifier) expired(delivery Delivery) bool {
+	if delivery.Attempts >= n.maxAttempts {
+		return true
+	}
+	return !delivery.CreatedAt.IsZero() && time.Since(delivery.CreatedAt) > n.maxAge
+}
+
+// expire gives up the delivery.
+func (n *WebhookNotifier) expire(delivery Delivery) {
+	logger.Log.Error("Giving up webhook delivery",
+		zap.String("url", delivery.URL),
+		zap.String("alert", delivery.Payload.Alert),
+		zap.Int("attempts", delivery.Attempts),
+		zap.String("last_error", delivery.LastError))
+
+	delivery.Status = DeliveryExpired
+	if err := n.deliveryLog.Record(delivery); err != nil {
+		logger.Log.Error("Failed to record webhook delivery", zap.Error(err))
+	}
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, delivery Delivery) error {
+	body, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if webhook, ok := n.webhook(delivery); ok && webhook.Secret != "" {
+		req.Header.Set(sign.HashSHA256, sign.GetHash(body, []byte(webhook.Secret)))
+	}
+
+	response, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected webhook response status: %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// webhook returns the configured webhook the delivery is addressed to. It
+// is not found if the webhook was removed from the config since the
+// delivery was queued.
+func (n *WebhookNotifier) webhook(delivery Delivery) (WebhookConfig, bool) {
+	if delivery.Webhook < 0 || delivery.Webhook >= len(n.webhooks) || n.webhooks[delivery.Webhook].URL != delivery.URL {
+		return WebhookConfig{}, false
+	}
+	return n.webhooks[delivery.Webhook], true
+}
+
+func newDeliveryID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,331 @@
+package alerting
+
+import (
+	"alerting-service/internal/config"
+	sign "alerting-service/internal/signature"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewPayload(t *testing.T) {
+	start := time.Now()
+	alert := Alert{
+		Rule:       Rule{Name: "HighHeap", MetricID: "HeapAlloc", Threshold: 100},
+		State:      StateInactive,
+		Value:      50,
+		ActiveAt:   start,
+		ResolvedAt: start.Add(time.Minute),
+	}
+
+	payload := NewPayload(alert)
+
+	if payload.State != PayloadResolved {
+		t.Errorf("expected resolved state, got %s", payload.State)
+	}
+	if payload.EndsAt == nil || !payload.EndsAt.Equal(alert.ResolvedAt) {
+		t.Errorf("expected ends_at %s, got %v", alert.ResolvedAt, payload.EndsAt)
+	}
+	if payload.Alert != "HighHeap" || payload.MetricID != "HeapAlloc" || payload.Threshold != 100 || payload.Value != 50 {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestWebhookNotifier_RetriesFlappingReceiver(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan Payload, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(sign.HashSHA256) != sign.GetHash(body, []byte("secret")) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var payload Payload
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	deliveryLog, err := NewDeliveryLog(filepath.Join(t.TempDir(), "deliveries.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	notifier := NewWebhookNotifier(server.Client(), NotificationConfig{
+		Webhooks: []WebhookConfig{{URL: server.URL, Secret: "secret"}},
+		Retries:  3,
+		Backoff:  config.Duration(time.Millisecond),
+	}, deliveryLog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	alert := Alert{Rule: Rule{Name: "HighHeap", MetricID: "HeapAlloc", Threshold: 100}, State: StateFiring, Value: 150}
+	if err := notifier.Notify(ctx, alert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case payload := <-received:
+		if payload.State != PayloadFiring || payload.Value != 150 {
+			t.Errorf("unexpected payload: %+v", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		pending, err := deliveryLog.Pending()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no pending deliveries, got %+v", pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookNotifier_RedeliversPendingOnStart(t *testing.T) {
+	received := make(chan Payload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer server.Close()
+
+	deliveryLog, err := NewDeliveryLog(filepath.Join(t.TempDir(), "deliveries.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	err = deliveryLog.Record(Delivery{
+		ID:       "1",
+		URL:      server.URL,
+		Payload:  Payload{Alert: "HighHeap", State: PayloadResolved},
+		Status:   DeliveryPending,
+		Attempts: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := NewWebhookNotifier(server.Client(), NotificationConfig{Webhooks: []WebhookConfig{{URL: server.URL}}}, deliveryLog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	select {
+	case payload := <-received:
+		if payload.Alert != "HighHeap" || payload.State != PayloadResolved {
+			t.Errorf("unexpected payload: %+v", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pending delivery was not redelivered")
+	}
+}
+
+func TestWebhookNotifier_ExpiresDeadReceiver(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	deliveryLog, err := NewDeliveryLog(filepath.Join(t.TempDir(), "deliveries.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	old := Delivery{ID: "old", URL: server.URL, Status: DeliveryPending, Attempts: 1, CreatedAt: time.Now().Add(-2 * time.Hour)}
+	failing := Delivery{ID: "failing", URL: server.URL, Status: DeliveryPending, Attempts: 1, CreatedAt: time.Now()}
+	for _, delivery := range []Delivery{old, failing} {
+		if err := deliveryLog.Record(delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifier := NewWebhookNotifier(server.Client(), NotificationConfig{
+		Webhooks:    []WebhookConfig{{URL: server.URL}},
+		Retries:     5,
+		Backoff:     config.Duration(time.Millisecond),
+		MaxAttempts: 3,
+		MaxAge:      config.Duration(time.Hour),
+	}, deliveryLog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pending, err := deliveryLog.Pending()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected deliveries to be given up, got %+v", pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected only the failing delivery to be retried up to 3 attempts, got %d calls", got)
+	}
+}
+
+func TestWebhookNotifier_SignsWithOwnSecret(t *testing.T) {
+	signatures := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.Header.Get(sign.HashSHA256) {
+		case sign.GetHash(body, []byte("first")):
+			signatures <- "first"
+		case sign.GetHash(body, []byte("second")):
+			signatures <- "second"
+		default:
+			signatures <- "unsigned"
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.Client(), NotificationConfig{
+		Webhooks: []WebhookConfig{{URL: server.URL, Secret: "first"}, {URL: server.URL, Secret: "second"}},
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	if err := notifier.Notify(ctx, Alert{Rule: Rule{Name: "HighHeap"}, State: StateFiring}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case secret := <-signatures:
+			got[secret] = true
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook was not delivered")
+		}
+	}
+	if !got["first"] || !got["second"] {
+		t.Errorf("expected each webhook signed with its own secret, got %v", got)
+	}
+}
+
+func TestWebhookNotifier_ExpiresRemovedWebhook(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	deliveryLog, err := NewDeliveryLog(filepath.Join(t.TempDir(), "deliveries.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	if err := deliveryLog.Record(Delivery{ID: "1", Webhook: 0, URL: server.URL + "/removed", Status: DeliveryPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := NewWebhookNotifier(server.Client(), NotificationConfig{Webhooks: []WebhookConfig{{URL: server.URL}}}, deliveryLog)
+	notifier.redeliver()
+
+	if calls.Load() != 0 {
+		t.Error("expected no delivery to a removed webhook")
+	}
+	if pending, _ := deliveryLog.Pending(); len(pending) != 0 {
+		t.Errorf("expected the delivery to be given up, got %+v", pending)
+	}
+}
+
+func TestWebhookNotifier_SlowReceiver(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	received := make(chan Payload, webhookQueueSize)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received <- payload
+	}))
+	defer fast.Close()
+
+	deliveryLog, err := NewDeliveryLog(filepath.Join(t.TempDir(), "deliveries.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deliveryLog.Close()
+
+	notifier := NewWebhookNotifier(http.DefaultClient, NotificationConfig{
+		Webhooks: []WebhookConfig{{URL: slow.URL}, {URL: fast.URL}},
+	}, deliveryLog)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*webhookQueueSize; i++ {
+			_ = notifier.Notify(ctx, Alert{Rule: Rule{Name: "HighHeap"}, State: StateFiring})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Notify blocked on a slow receiver")
+	}
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("fast webhook was held up by the slow one")
+	}
+
+	pending, err := deliveryLog.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) < webhookQueueSize {
+		t.Errorf("expected deliveries that did not fit in the queue to stay pending, got %d", len(pending))
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// DBStore looks up tokens in the api_tokens table. A token is revoked by
+// setting revoked_at, which takes effect on the next request.
+type DBStore struct {
+	db *sql.DB
+}
+
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Lookup returns the token with the secret unless it is revoked.
+func (s *DBStore) Lookup(ctx context.Context, secret string) (*Token, error) {
+	token := Token{Hash: HashToken(secret)}
+	var prefixes []byte
+
+	row := s.db.QueryRowContext(ctx,
+		"SELECT name, scope, prefixes FROM api_tokens WHERE token_sha256 = $1 AND revoked_at IS NULL", token.Hash)
+	if err := row.Scan(&token.Name, &token.Scope, &prefixes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnknownToken
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(prefixes, &token.Prefixes); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
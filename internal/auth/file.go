@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// tokensFile is the format of the tokens file.
+type tokensFile struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// LoadFile reads and validates the tokens file. Token names and digests
+// must be unique.
+func LoadFile(filename string) ([]Token, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file tokensFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("tokens file %s: %w", filename, err)
+	}
+
+	names := make(map[string]struct{}, len(file.Tokens))
+	hashes := make(map[string]struct{}, len(file.Tokens))
+	for i := range file.Tokens {
+		token := &file.Tokens[i]
+		token.Hash = strings.ToLower(token.Hash)
+		if err := token.Validate(); err != nil {
+			return nil, err
+		}
+
+		if _, ok := names[token.Name]; ok {
+			return nil, fmt.Errorf("%w name %q", ErrDuplicateToken, token.Name)
+		}
+		if _, ok := hashes[token.Hash]; ok {
+			return nil, fmt.Errorf("%w digest for %q", ErrDuplicateToken, token.Name)
+		}
+		names[token.Name] = struct{}{}
+		hashes[token.Hash] = struct{}{}
+	}
+
+	return file.Tokens, nil
+}
+
+// FileStore holds the tokens read from a file; they can be replaced while
+// the server is running.
+type FileStore struct {
+	tokens atomic.Pointer[map[string]Token]
+}
+
+// NewFileStore creates a store with the tokens.
+func NewFileStore(tokens []Token) *FileStore {
+	store := &FileStore{}
+	store.Store(tokens)
+	return store
+}
+
+// Store replaces the tokens.
+func (s *FileStore) Store(tokens []Token) {
+	byHash := make(map[string]Token, len(tokens))
+	for _, token := range tokens {
+		byHash[token.Hash] = token
+	}
+	s.tokens.Store(&byHash)
+}
+
+// Lookup returns the token with the secret.
+func (s *FileStore) Lookup(_ context.Context, secret string) (*Token, error) {
+	token, ok := (*s.tokens.Load())[HashToken(secret)]
+	if !ok {
+		return nil, ErrUnknownToken
+	}
+	return &token, nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTokensFile(t *testing.T, content string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write tokens file: %v", err)
+	}
+	return filename
+}
+
+func TestLoadFile(t *testing.T) {
+	hash := HashToken("secret")
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{name: "valid", content: `{"tokens": [{"name": "web-1", "token_sha256": "` + hash + `", "scope": "write", "prefixes": ["web_"]}]}`},
+		{name: "empty name", content: `{"tokens": [{"token_sha256": "` + hash + `", "scope": "write"}]}`, wantErr: ErrEmptyTokenName},
+		{name: "invalid scope", content: `{"tokens": [{"name": "web-1", "token_sha256": "` + hash + `", "scope": "root"}]}`, wantErr: ErrInvalidScope},
+		{name: "invalid hash", content: `{"tokens": [{"name": "web-1", "token_sha256": "secret", "scope": "read"}]}`, wantErr: ErrInvalidTokenHash},
+		{name: "duplicate name", content: `{"tokens": [{"name": "a", "token_sha256": "` + hash + `", "scope": "read"}, {"name": "a", "token_sha256": "` + HashToken("other") + `", "scope": "read"}]}`, wantErr: ErrDuplicateToken},
+		{name: "duplicate hash", content: `{"tokens": [{"name": "a", "token_sha256": "` + hash + `", "scope": "read"}, {"name": "b", "token_sha256": "` + hash + `", "scope": "read"}]}`, wantErr: ErrDuplicateToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeTokensFile(t, tt.content))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store := NewFileStore([]Token{{Name: "web-1", Hash: HashToken("secret"), Scope: ScopeWrite}})
+
+	token, err := store.Lookup(context.Background(), "secret")
+	if err != nil || token.Name != "web-1" {
+		t.Fatalf("expected web-1, got %+v, %v", token, err)
+	}
+
+	store.Store(nil)
+	if _, err := store.Lookup(context.Background(), "secret"); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("expected revoked token to be unknown, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"alerting-service/internal/logger"
+	"alerting-service/internal/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// NamesFunc returns the names of the metrics a request accesses.
+type NamesFunc func(r *http.Request) ([]string, error)
+
+// URLParam returns the metric name from the URL parameter.
+func URLParam(param string) NamesFunc {
+	return func(r *http.Request) ([]string, error) {
+		return []string{chi.URLParam(r, param)}, nil
+	}
+}
+
+// QueryParam returns the metric name from the URL query parameter.
+func QueryParam(param string) NamesFunc {
+	return func(r *http.Request) ([]string, error) {
+		return []string{r.URL.Query().Get(param)}, nil
+	}
+}
+
+// JSONMetrics returns the metric names from a JSON body holding a single
+// metric or a batch. The body is left readable for the handler.
+func JSONMetrics(r *http.Request) ([]string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var metrics []models.Metrics
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(body, &metrics)
+	} else {
+		var metric models.Metrics
+		err = json.Unmarshal(body, &metric)
+		metrics = append(metrics, metric)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(metrics))
+	for _, metric := range metrics {
+		names = append(names, metric.ID)
+	}
+	return names, nil
+}
+
+// Middleware requires a bearer token with the scope that is allowed to
+// access every metric of the request. Missing or unknown tokens are
+// rejected with 401, tokens out of scope with 403. The token is passed on
+// in the request context, so handlers that list metrics can filter them
+// with FilterMetrics. A nil store disables authentication.
+func Middleware(store Store, scope Scope, names NamesFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if store == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := authenticate(r, store)
+			if err != nil {
+				if !errors.Is(err, ErrMissingBearer) && !errors.Is(err, ErrUnknownToken) {
+					logger.Log.Error("Failed to look up token", zap.Error(err))
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if err := authorize(r, token, scope, names); err != nil {
+				logger.Log.Warn("Rejected request out of token scope",
+					zap.String("token", token.Name),
+					zap.String("path", r.URL.Path),
+					zap.Error(err))
+				if errors.Is(err, ErrInsufficientScope) || errors.Is(err, ErrMetricNotAllowed) {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+				} else {
+					http.Error(w, "Bad request", http.StatusBadRequest)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+		})
+	}
+}
+
+// tokenKey is the request context key of the authenticated token.
+type tokenKey struct{}
+
+// TokenFromContext returns the token the request was authenticated with.
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*Token)
+	return token, ok
+}
+
+// FilterMetrics returns the metrics the token of the request context is
+// allowed to read. All metrics are returned if authentication is disabled.
+func FilterMetrics(ctx context.Context, metrics []models.Metrics) []models.Metrics {
+	token, ok := TokenFromContext(ctx)
+	if !ok || len(token.Prefixes) == 0 {
+		return metrics
+	}
+
+	allowed := make([]models.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if token.AllowsMetric(metric.ID) {
+			allowed = append(allowed, metric)
+		}
+	}
+	return allowed
+}
+
+// authenticate returns the token of the Authorization header.
+func authenticate(r *http.Request, store Store) (*Token, error) {
+	scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || secret == "" {
+		return nil, ErrMissingBearer
+	}
+
+	return store.Lookup(r.Context(), strings.TrimSpace(secret))
+}
+
+// authorize checks the scope and, if the token has a prefix allowlist, the
+// metrics of the request.
+func authorize(r *http.Request, token *Token, scope Scope, names NamesFunc) error {
+	if !token.Allows(scope) {
+		return ErrInsufficientScope
+	}
+	if len(token.Prefixes) == 0 || names == nil {
+		return nil
+	}
+
+	metricNames, err := names(r)
+	if err != nil {
+		return err
+	}
+	for _, name := range metricNames {
+		if !token.AllowsMetric(name) {
+			return ErrMetricNotAllowed
+		}
+	}
+
+	return nil
+}
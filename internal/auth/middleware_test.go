@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMiddleware(t *testing.T) {
+	store := NewFileStore([]Token{
+		{Name: "web-1", Hash: HashToken("writer"), Scope: ScopeWrite, Prefixes: []string{"web_"}},
+		{Name: "dashboard", Hash: HashToken("reader"), Scope: ScopeRead},
+		{Name: "ops", Hash: HashToken("admin"), Scope: ScopeAdmin},
+	})
+
+	r := chi.NewRouter()
+	r.Route("/updates", func(r chi.Router) {
+		r.Use(Middleware(store, ScopeWrite, JSONMetrics))
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(w, r.Body)
+		})
+	})
+	r.Route("/value/{metricType}/{metricName}", func(r chi.Router) {
+		r.Use(Middleware(store, ScopeRead, URLParam("metricName")))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{name: "missing token", method: http.MethodPost, path: "/updates/", body: `[]`, want: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodPost, path: "/updates/", token: "guess", body: `[]`, want: http.StatusUnauthorized},
+		{name: "allowed batch", method: http.MethodPost, path: "/updates/", token: "writer", body: `[{"id":"web_requests","type":"counter","delta":1}]`, want: http.StatusOK},
+		{name: "metric outside prefixes", method: http.MethodPost, path: "/updates/", token: "writer", body: `[{"id":"web_requests","type":"counter","delta":1},{"id":"db_queries","type":"counter","delta":1}]`, want: http.StatusForbidden},
+		{name: "single metric", method: http.MethodPost, path: "/updates/", token: "writer", body: `{"id":"db_queries","type":"counter","delta":1}`, want: http.StatusForbidden},
+		{name: "invalid body", method: http.MethodPost, path: "/updates/", token: "writer", body: `[`, want: http.StatusBadRequest},
+		{name: "read token writes", method: http.MethodPost, path: "/updates/", token: "reader", body: `[]`, want: http.StatusForbidden},
+		{name: "read token reads", method: http.MethodGet, path: "/value/counter/db_queries", token: "reader", want: http.StatusOK},
+		{name: "write token reads", method: http.MethodGet, path: "/value/counter/web_requests", token: "writer", want: http.StatusForbidden},
+		{name: "admin writes", method: http.MethodPost, path: "/updates/", token: "admin", body: `[{"id":"db_queries","type":"counter","delta":1}]`, want: http.StatusOK},
+		{name: "admin reads", method: http.MethodGet, path: "/value/counter/db_queries", token: "admin", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("want status %d, got %d", tt.want, rec.Code)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != tt.body {
+				t.Errorf("expected body to reach the handler, got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddleware_NoStore(t *testing.T) {
+	handler := Middleware(nil, ScopeWrite, JSONMetrics)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/updates/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected authentication to be disabled, got %d", rec.Code)
+	}
+}
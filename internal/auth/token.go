@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Scope is the set of calls a token may make.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"  // Read metric values
+	ScopeWrite Scope = "write" // Update metrics
+	ScopeAdmin Scope = "admin" // Any call on any metric
+)
+
+var (
+	ErrUnknownToken      = errors.New("unknown or revoked token")
+	ErrInvalidScope      = errors.New("token scope must be read, write or admin")
+	ErrEmptyTokenName    = errors.New("token name is empty")
+	ErrDuplicateToken    = errors.New("duplicate token")
+	ErrInvalidTokenHash  = errors.New("token_sha256 must be a hex-encoded SHA-256 digest")
+	ErrMissingBearer     = errors.New("missing bearer token")
+	ErrInsufficientScope = errors.New("token scope does not allow the call")
+	ErrMetricNotAllowed  = errors.New("token is not allowed to access the metric")
+)
+
+// Token is an API token issued to a single agent or user. Only the SHA-256
+// digest of the secret is stored.
+type Token struct {
+	Name     string   `json:"name"`         // Agent or user the token is issued to
+	Hash     string   `json:"token_sha256"` // Hex-encoded SHA-256 of the secret
+	Scope    Scope    `json:"scope"`        // Calls the token may make
+	Prefixes []string `json:"prefixes"`     // Allowed metric name prefixes, empty for any metric
+}
+
+// Store looks up tokens by their secret.
+type Store interface {
+	// Lookup returns the token with the secret, or ErrUnknownToken.
+	Lookup(ctx context.Context, secret string) (*Token, error)
+}
+
+// HashToken returns the hex-encoded SHA-256 digest of the token secret.
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate checks the scope and the digest of the token.
+func (t *Token) Validate() error {
+	if t.Name == "" {
+		return ErrEmptyTokenName
+	}
+	if t.Scope != ScopeRead && t.Scope != ScopeWrite && t.Scope != ScopeAdmin {
+		return fmt.Errorf("token %q: %w, got %q", t.Name, ErrInvalidScope, t.Scope)
+	}
+	if digest, err := hex.DecodeString(t.Hash); err != nil || len(digest) != sha256.Size {
+		return fmt.Errorf("token %q: %w", t.Name, ErrInvalidTokenHash)
+	}
+	return nil
+}
+
+// Allows reports whether the token may make calls that require the scope.
+func (t *Token) Allows(scope Scope) bool {
+	return t.Scope == ScopeAdmin || t.Scope == scope
+}
+
+// AllowsMetric reports whether the metric name matches one of the allowed
+// prefixes.
+func (t *Token) AllowsMetric(name string) bool {
+	if len(t.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range t.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
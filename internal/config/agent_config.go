@@ -2,17 +2,40 @@ package config
 
 import (
 	"encoding/json"
-	"os"
-	"time"
 )
 
 type AgentConfig struct {
-	Address        string        `json:"address"`
-	ReportInterval time.Duration `json:"report_interval"`
-	PollInterval   time.Duration `json:"poll_interval"`
-	CryptoKey      string        `json:"crypto_key"`
-	HashKey        string        `json:"hash_key"`
-	RateLimit      int           `json:"rate_limit"`
+	Address        string                     `json:"address"`
+	LogLevel       string                     `json:"log_level"`
+	ReportInterval Duration                   `json:"report_interval"`
+	PollInterval   Duration                   `json:"poll_interval"`
+	CryptoKey      string                     `json:"crypto_key"`
+	HashKey        string                     `json:"hash_key"`
+	RateLimit      int                        `json:"rate_limit"`
+	BatchSize      int                        `json:"batch_size"`
+	Labels         map[string]string          `json:"labels"`
+	OutboxDir      string                     `json:"outbox_dir"`
+	OutboxMaxSize  int64                      `json:"outbox_max_size"`
+	OutboxMaxAge   Duration                   `json:"outbox_max_age"`
+	RetryAttempts  int                        `json:"retry_attempts"`
+	RetryMinDelay  Duration                   `json:"retry_min_delay"`
+	RetryMaxDelay  Duration                   `json:"retry_max_delay"`
+	ProcRoot       string                     `json:"proc_root"`
+	Collectors     map[string]CollectorConfig `json:"collectors"`
+	MetricsAddress string                     `json:"metrics_address"`
+	TLS            bool                       `json:"tls"`
+	TLSCA          string                     `json:"tls_ca"`
+	TLSCert        string                     `json:"tls_cert"`
+	TLSKey         string                     `json:"tls_key"`
+	TLSServerName  string                     `json:"tls_server_name"`
+	Token          string                     `json:"token"`
+}
+
+// CollectorConfig configures a single agent collector.
+type CollectorConfig struct {
+	Enabled  *bool           `json:"enabled"`  // Overrides whether the collector runs
+	Interval Duration        `json:"interval"` // Overrides the collection interval
+	Settings json.RawMessage `json:"settings"` // Collector specific settings
 }
 
 func LoadAgentConfig(filename string) (*AgentConfig, error) {
@@ -20,13 +43,8 @@ func LoadAgentConfig(filename string) (*AgentConfig, error) {
 		return &AgentConfig{}, nil
 	}
 
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-
 	var config AgentConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := loadJSONFile(filename, &config); err != nil {
 		return nil, err
 	}
 
@@ -4,63 +4,326 @@ import (
 	"flag"
 	"os"
 	"strconv"
+	"time"
 )
 
 // Config holds configuration parameters for the agent.
 type Config struct {
-	RunAddr         string // Server run address
-	LogLevel        string // Log level
-	StoreInterval   int    // Interval in seconds for writing metrics to a file
-	FileStoragePath string // Path to the file for storing metrics
-	Restore         bool   // Whether to restore metrics from the file on startup
-	PollInterval    int    // Interval for polling runtime metrics, in seconds
-	ReportInterval  int    // Interval for reporting metrics to the server, in seconds
-	HashKey         string // Secret key for signing metric payloads
-	RateLimit       int    // Number of parallel workers for sending metrics
-	CryptoKey       string // Path to the cryptographic key file
+	RunAddr           string                     // Server run address
+	LogLevel          string                     // Log level
+	StoreInterval     int                        // Interval in seconds for writing metrics to a file
+	FileStoragePath   string                     // Path to the file for storing metrics
+	Restore           bool                       // Whether to restore metrics from the file on startup
+	PollInterval      int                        // Interval for polling runtime metrics, in seconds
+	ReportInterval    int                        // Interval for reporting metrics to the server, in seconds
+	HashKey           string                     // Secret key for signing metric payloads
+	RateLimit         int                        // Number of parallel workers for sending metrics
+	BatchSize         int                        // Maximum number of metrics sent in one request
+	CryptoKey         string                     // Path to the cryptographic key file
+	Labels            map[string]string          // Static labels attached to every metric
+	OutboxDir         string                     // Directory for batches the server did not accept, empty to disable
+	OutboxMaxSize     int64                      // Maximum total size of the outbox, in bytes
+	OutboxMaxAge      time.Duration              // Maximum age of a queued batch
+	RetryAttempts     int                        // Attempts to send a batch, including the first one
+	RetryMinDelay     time.Duration              // Delay before the first retry, doubled on each retry
+	RetryMaxDelay     time.Duration              // Maximum delay between retries
+	ProcRoot          string                     // Mount point of the proc filesystem, empty to disable host metrics
+	EnabledCollectors []string                   // Exact set of collectors to run, empty for the configured ones
+	Collectors        map[string]CollectorConfig // Per-collector configuration
+	MetricsAddr       string                     // Address of the agent health and metrics endpoint, empty to disable
+	TLS               bool                       // Whether to send metrics over HTTPS
+	TLSCA             string                     // Path to the CA bundle verifying the server, empty for the system roots
+	TLSCert           string                     // Path to the client certificate presented to the server
+	TLSKey            string                     // Path to the client certificate key
+	TLSServerName     string                     // Name the server certificate is verified against, empty for the address host
+	Token             string                     // API token sent as a bearer token, empty to not send one
 }
 
-// GetConfig parses configuration from command-line flags and environment variables.
-func GetConfig() *Config {
+// TLSEnabled reports whether metrics are sent over HTTPS. Setting a CA or a
+// client certificate implies it.
+func (c *Config) TLSEnabled() bool {
+	return c.TLS || c.TLSCA != "" || c.TLSCert != ""
+}
+
+// GetConfig parses the configuration from command-line flags, environment
+// variables and the config file set with -c/-config or CONFIG. A flag takes
+// precedence over the environment, and the environment over the file.
+func GetConfig() (*Config, error) {
+	return parseConfig(flag.CommandLine, os.Args[1:])
+}
+
+// ReloadConfig parses the configuration again from the original command
+// line, the environment and the config file.
+func ReloadConfig() (*Config, error) {
+	return parseConfig(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+}
+
+func parseConfig(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := &Config{}
 
-	flag.StringVar(&cfg.CryptoKey, "crypto-key", "", "path to the public/private key file")
-	flag.StringVar(&cfg.RunAddr, "a", "localhost:8080", "address and port to run server")
-	flag.IntVar(&cfg.PollInterval, "p", 2, "how often to get metrics from runtime, seconds")
-	flag.IntVar(&cfg.ReportInterval, "r", 10, "how often to send metrics to server, seconds")
-	flag.IntVar(&cfg.RateLimit, "l", 3, "count of workers for sending metrics")
-	flag.StringVar(&cfg.HashKey, "k", "", "hash key string for generation signature")
-	flag.Parse()
+	var configFile string
+	fs.StringVar(&configFile, "c", "", "path to config file")
+	fs.StringVar(&configFile, "config", "", "path to config file")
+	fs.StringVar(&cfg.CryptoKey, "crypto-key", "", "path to the public/private key file")
+	fs.StringVar(&cfg.RunAddr, "a", "localhost:8080", "address and port to run server")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "log level")
+	fs.IntVar(&cfg.PollInterval, "p", 2, "how often to get metrics from runtime, seconds")
+	fs.IntVar(&cfg.ReportInterval, "r", 10, "how often to send metrics to server, seconds")
+	fs.IntVar(&cfg.RateLimit, "l", 3, "count of workers for sending metrics")
+	fs.IntVar(&cfg.BatchSize, "b", 100, "maximum count of metrics sent in one request")
+	fs.StringVar(&cfg.HashKey, "k", "", "hash key string for generation signature")
+	fs.StringVar(&cfg.OutboxDir, "outbox-dir", "", "directory for unsent metrics, empty to disable")
+	fs.Int64Var(&cfg.OutboxMaxSize, "outbox-max-size", 10<<20, "maximum size of unsent metrics, bytes")
+	fs.DurationVar(&cfg.OutboxMaxAge, "outbox-max-age", 24*time.Hour, "maximum age of unsent metrics")
+	fs.IntVar(&cfg.RetryAttempts, "retry-attempts", 3, "attempts to send a batch, including the first one")
+	fs.DurationVar(&cfg.RetryMinDelay, "retry-min-delay", time.Second, "delay before the first retry")
+	fs.DurationVar(&cfg.RetryMaxDelay, "retry-max-delay", 10*time.Second, "maximum delay between retries")
+	fs.StringVar(&cfg.ProcRoot, "proc-root", "/proc", "mount point of the proc filesystem, empty to disable host metrics")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "address of the agent health and metrics endpoint, empty to disable")
+	fs.BoolVar(&cfg.TLS, "tls", false, "send metrics over HTTPS")
+	fs.StringVar(&cfg.TLSCA, "tls-ca", "", "path to the CA bundle verifying the server, empty for the system roots")
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "path to the client certificate")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "path to the client certificate key")
+	fs.StringVar(&cfg.TLSServerName, "tls-server-name", "", "name the server certificate is verified against")
+	fs.StringVar(&cfg.Token, "token", "", "API token sent to the server")
+	collectors := fs.String("collectors", "", "comma-separated list of collectors to run, e.g. runtime,proc")
+	labels := fs.String("labels", "", "static labels attached to every metric, e.g. env=prod,dc=eu-1")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if envConfigFile := os.Getenv("CONFIG"); envConfigFile != "" && !set["c"] && !set["config"] {
+		configFile = envConfigFile
+	}
+
+	if configFile != "" {
+		agentConfig, err := LoadAgentConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		applyAgentConfig(cfg, agentConfig, set)
+	}
+
+	applyAgentEnv(cfg, set)
+
+	if envCollectors := os.Getenv("COLLECTORS"); envCollectors != "" && !set["collectors"] {
+		*collectors = envCollectors
+	}
+
+	if enabled := ParseList(*collectors); enabled != nil {
+		cfg.EnabledCollectors = enabled
+	}
+
+	if envLabels := os.Getenv("LABELS"); envLabels != "" && !set["labels"] {
+		*labels = envLabels
+	}
+
+	if *labels != "" {
+		parsed, err := ParseLabels(*labels)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Labels = parsed
+	}
+
+	return cfg, nil
+}
 
-	if envRunAddr := os.Getenv("ADDRESS"); envRunAddr != "" {
+// applyAgentConfig applies the values set in the config file to the
+// options that were not set with a flag.
+func applyAgentConfig(cfg *Config, agentConfig *AgentConfig, set map[string]bool) {
+	if agentConfig.Address != "" && !set["a"] {
+		cfg.RunAddr = agentConfig.Address
+	}
+	if agentConfig.LogLevel != "" && !set["log-level"] {
+		cfg.LogLevel = agentConfig.LogLevel
+	}
+	if agentConfig.ReportInterval > 0 && !set["r"] {
+		cfg.ReportInterval = durationSeconds(agentConfig.ReportInterval)
+	}
+	if agentConfig.PollInterval > 0 && !set["p"] {
+		cfg.PollInterval = durationSeconds(agentConfig.PollInterval)
+	}
+	if agentConfig.CryptoKey != "" && !set["crypto-key"] {
+		cfg.CryptoKey = agentConfig.CryptoKey
+	}
+	if agentConfig.HashKey != "" && !set["k"] {
+		cfg.HashKey = agentConfig.HashKey
+	}
+	if agentConfig.RateLimit > 0 && !set["l"] {
+		cfg.RateLimit = agentConfig.RateLimit
+	}
+	if agentConfig.BatchSize > 0 && !set["b"] {
+		cfg.BatchSize = agentConfig.BatchSize
+	}
+	if agentConfig.OutboxDir != "" && !set["outbox-dir"] {
+		cfg.OutboxDir = agentConfig.OutboxDir
+	}
+	if agentConfig.OutboxMaxSize > 0 && !set["outbox-max-size"] {
+		cfg.OutboxMaxSize = agentConfig.OutboxMaxSize
+	}
+	if agentConfig.OutboxMaxAge > 0 && !set["outbox-max-age"] {
+		cfg.OutboxMaxAge = agentConfig.OutboxMaxAge.Duration()
+	}
+	if agentConfig.RetryAttempts > 0 && !set["retry-attempts"] {
+		cfg.RetryAttempts = agentConfig.RetryAttempts
+	}
+	if agentConfig.RetryMinDelay > 0 && !set["retry-min-delay"] {
+		cfg.RetryMinDelay = agentConfig.RetryMinDelay.Duration()
+	}
+	if agentConfig.RetryMaxDelay > 0 && !set["retry-max-delay"] {
+		cfg.RetryMaxDelay = agentConfig.RetryMaxDelay.Duration()
+	}
+	if agentConfig.ProcRoot != "" && !set["proc-root"] {
+		cfg.ProcRoot = agentConfig.ProcRoot
+	}
+	if agentConfig.MetricsAddress != "" && !set["metrics-addr"] {
+		cfg.MetricsAddr = agentConfig.MetricsAddress
+	}
+	if agentConfig.TLS && !set["tls"] {
+		cfg.TLS = agentConfig.TLS
+	}
+	if agentConfig.TLSCA != "" && !set["tls-ca"] {
+		cfg.TLSCA = agentConfig.TLSCA
+	}
+	if agentConfig.TLSCert != "" && !set["tls-cert"] {
+		cfg.TLSCert = agentConfig.TLSCert
+	}
+	if agentConfig.TLSKey != "" && !set["tls-key"] {
+		cfg.TLSKey = agentConfig.TLSKey
+	}
+	if agentConfig.TLSServerName != "" && !set["tls-server-name"] {
+		cfg.TLSServerName = agentConfig.TLSServerName
+	}
+	if agentConfig.Token != "" && !set["token"] {
+		cfg.Token = agentConfig.Token
+	}
+	if len(agentConfig.Labels) > 0 {
+		cfg.Labels = agentConfig.Labels
+	}
+
+	cfg.Collectors = agentConfig.Collectors
+}
+
+// applyAgentEnv applies the environment variables to the options that were
+// not set with a flag.
+func applyAgentEnv(cfg *Config, set map[string]bool) {
+	if envRunAddr := os.Getenv("ADDRESS"); envRunAddr != "" && !set["a"] {
 		cfg.RunAddr = envRunAddr
 	}
 
-	if envReportInterval := os.Getenv("REPORT_INTERVAL"); envReportInterval != "" {
-		if envReportInterval, err := strconv.Atoi(envReportInterval); err == nil {
-			cfg.ReportInterval = envReportInterval
+	if envLogLevel := os.Getenv("LOG_LEVEL"); envLogLevel != "" && !set["log-level"] {
+		cfg.LogLevel = envLogLevel
+	}
+
+	if envReportInterval := os.Getenv("REPORT_INTERVAL"); envReportInterval != "" && !set["r"] {
+		if val, err := strconv.Atoi(envReportInterval); err == nil {
+			cfg.ReportInterval = val
 		}
 	}
 
-	if envPollInterval := os.Getenv("POLL_INTERVAL"); envPollInterval != "" {
+	if envPollInterval := os.Getenv("POLL_INTERVAL"); envPollInterval != "" && !set["p"] {
 		if val, err := strconv.Atoi(envPollInterval); err == nil {
 			cfg.PollInterval = val
 		}
 	}
 
-	if envHashKey := os.Getenv("KEY"); envHashKey != "" {
+	if envHashKey := os.Getenv("KEY"); envHashKey != "" && !set["k"] {
 		cfg.HashKey = envHashKey
 	}
 
-	if envRateLimit := os.Getenv("RATE_LIMIT"); envRateLimit != "" {
-		if envRateLimit, err := strconv.Atoi(envRateLimit); err == nil {
-			cfg.RateLimit = envRateLimit
+	if envRateLimit := os.Getenv("RATE_LIMIT"); envRateLimit != "" && !set["l"] {
+		if val, err := strconv.Atoi(envRateLimit); err == nil {
+			cfg.RateLimit = val
+		}
+	}
+
+	if envBatchSize := os.Getenv("BATCH_SIZE"); envBatchSize != "" && !set["b"] {
+		if val, err := strconv.Atoi(envBatchSize); err == nil {
+			cfg.BatchSize = val
 		}
 	}
 
-	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
+	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" && !set["crypto-key"] {
 		cfg.CryptoKey = envCryptoKey
 	}
 
-	return cfg
+	if envOutboxDir, ok := os.LookupEnv("OUTBOX_DIR"); ok && !set["outbox-dir"] {
+		cfg.OutboxDir = envOutboxDir
+	}
+
+	if envOutboxMaxSize := os.Getenv("OUTBOX_MAX_SIZE"); envOutboxMaxSize != "" && !set["outbox-max-size"] {
+		if val, err := strconv.ParseInt(envOutboxMaxSize, 10, 64); err == nil {
+			cfg.OutboxMaxSize = val
+		}
+	}
+
+	if envOutboxMaxAge := os.Getenv("OUTBOX_MAX_AGE"); envOutboxMaxAge != "" && !set["outbox-max-age"] {
+		if val, err := time.ParseDuration(envOutboxMaxAge); err == nil {
+			cfg.OutboxMaxAge = val
+		}
+	}
+
+	if envRetryAttempts := os.Getenv("RETRY_ATTEMPTS"); envRetryAttempts != "" && !set["retry-attempts"] {
+		if val, err := strconv.Atoi(envRetryAttempts); err == nil {
+			cfg.RetryAttempts = val
+		}
+	}
+
+	if envRetryMinDelay := os.Getenv("RETRY_MIN_DELAY"); envRetryMinDelay != "" && !set["retry-min-delay"] {
+		if val, err := time.ParseDuration(envRetryMinDelay); err == nil {
+			cfg.RetryMinDelay = val
+		}
+	}
+
+	if envRetryMaxDelay := os.Getenv("RETRY_MAX_DELAY"); envRetryMaxDelay != "" && !set["retry-max-delay"] {
+		if val, err := time.ParseDuration(envRetryMaxDelay); err == nil {
+			cfg.RetryMaxDelay = val
+		}
+	}
+
+	if envProcRoot, ok := os.LookupEnv("PROC_ROOT"); ok && !set["proc-root"] {
+		cfg.ProcRoot = envProcRoot
+	}
+
+	if envMetricsAddr, ok := os.LookupEnv("METRICS_ADDRESS"); ok && !set["metrics-addr"] {
+		cfg.MetricsAddr = envMetricsAddr
+	}
+
+	if envTLS := os.Getenv("TLS"); envTLS != "" && !set["tls"] {
+		if val, err := strconv.ParseBool(envTLS); err == nil {
+			cfg.TLS = val
+		}
+	}
+
+	if envTLSCA := os.Getenv("TLS_CA"); envTLSCA != "" && !set["tls-ca"] {
+		cfg.TLSCA = envTLSCA
+	}
+
+	if envTLSCert := os.Getenv("TLS_CERT"); envTLSCert != "" && !set["tls-cert"] {
+		cfg.TLSCert = envTLSCert
+	}
+
+	if envTLSKey := os.Getenv("TLS_KEY"); envTLSKey != "" && !set["tls-key"] {
+		cfg.TLSKey = envTLSKey
+	}
+
+	if envTLSServerName := os.Getenv("TLS_SERVER_NAME"); envTLSServerName != "" && !set["tls-server-name"] {
+		cfg.TLSServerName = envTLSServerName
+	}
+
+	if envToken := os.Getenv("TOKEN"); envToken != "" && !set["token"] {
+		cfg.Token = envToken
+	}
+}
+
+// durationSeconds converts a config file duration to whole seconds, rounding
+// sub-second intervals up so they are not disabled.
+func durationSeconds(d Duration) int {
+	return max(1, int(d.Duration().Seconds()))
 }
@@ -1,18 +1,27 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetConfig_FromEnv(t *testing.T) {
-	_ = os.Setenv("ADDRESS", "127.0.0.1:9999")
-	_ = os.Setenv("REPORT_INTERVAL", "15")
-	_ = os.Setenv("POLL_INTERVAL", "5")
-	_ = os.Setenv("KEY", "secret")
-	_ = os.Setenv("RATE_LIMIT", "10")
+	t.Setenv("ADDRESS", "127.0.0.1:9999")
+	t.Setenv("REPORT_INTERVAL", "15")
+	t.Setenv("POLL_INTERVAL", "5")
+	t.Setenv("KEY", "secret")
+	t.Setenv("RATE_LIMIT", "10")
 
-	cfg := GetConfig()
+	cfg, err := GetConfig()
+	if err != nil {
+		t.Fatalf("GetConfig returned error: %v", err)
+	}
 
 	if cfg.RunAddr != "127.0.0.1:9999" {
 		t.Errorf("RunAddr = %s; want 127.0.0.1:9999", cfg.RunAddr)
@@ -30,3 +39,134 @@ func TestGetConfig_FromEnv(t *testing.T) {
 		t.Errorf("RateLimit = %d; want 10", cfg.RateLimit)
 	}
 }
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "agent.json")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestParseConfig_Precedence(t *testing.T) {
+	filename := writeConfigFile(t, `{
+		"address": "file:8080",
+		"report_interval": "20s",
+		"poll_interval": "500ms",
+		"hash_key": "file-key",
+		"rate_limit": 7,
+		"outbox_max_age": "1h",
+		"retry_min_delay": "2s",
+		"labels": {"env": "file"},
+		"metrics_address": "localhost:9100",
+		"collectors": {"proc": {"interval": "5s"}}
+	}`)
+
+	t.Setenv("CONFIG", filename)
+	t.Setenv("ADDRESS", "env:8080")
+	t.Setenv("KEY", "env-key")
+	t.Setenv("RATE_LIMIT", "")
+
+	cfg, err := parseConfig(flag.NewFlagSet("agent", flag.ContinueOnError), []string{"-a", "flag:8080", "-labels", "env=flag"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.RunAddr != "flag:8080" {
+		t.Errorf("RunAddr = %s; want flag value", cfg.RunAddr)
+	}
+	if cfg.HashKey != "env-key" {
+		t.Errorf("HashKey = %s; want env value", cfg.HashKey)
+	}
+	if cfg.RateLimit != 7 || cfg.ReportInterval != 20 || cfg.PollInterval != 1 {
+		t.Errorf("unexpected file values: rate limit %d, report %d, poll %d", cfg.RateLimit, cfg.ReportInterval, cfg.PollInterval)
+	}
+	if cfg.OutboxMaxAge != time.Hour || cfg.RetryMinDelay != 2*time.Second {
+		t.Errorf("unexpected durations: %s, %s", cfg.OutboxMaxAge, cfg.RetryMinDelay)
+	}
+	if cfg.BatchSize != 100 {
+		t.Errorf("BatchSize = %d; want default 100", cfg.BatchSize)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]string{"env": "flag"}) {
+		t.Errorf("Labels = %v; want flag value", cfg.Labels)
+	}
+	if cfg.MetricsAddr != "localhost:9100" {
+		t.Errorf("MetricsAddr = %s; want file value", cfg.MetricsAddr)
+	}
+	if cfg.Collectors["proc"].Interval.Duration() != 5*time.Second {
+		t.Errorf("unexpected collectors %+v", cfg.Collectors)
+	}
+}
+
+func TestParseConfig_FileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown field", content: `{"adress": "localhost:8080"}`, wantErr: `unknown field "adress"`},
+		{name: "invalid duration", content: `{"report_interval": "ten"}`, wantErr: `"ten"`},
+		{name: "invalid type", content: `{"rate_limit": "3"}`, wantErr: "rate_limit"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := writeConfigFile(t, test.content)
+
+			_, err := parseConfig(flag.NewFlagSet("agent", flag.ContinueOnError), []string{"-c", filename})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) || !strings.Contains(err.Error(), filename) {
+				t.Errorf("expected error mentioning %q and the file, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseConfig_InvalidLabels(t *testing.T) {
+	_, err := parseConfig(flag.NewFlagSet("agent", flag.ContinueOnError), []string{"-labels", "env"})
+	if !errors.Is(err, ErrInvalidLabels) {
+		t.Errorf("expected ErrInvalidLabels, got %v", err)
+	}
+}
+
+func TestParseConfig_Outbox(t *testing.T) {
+	cfg, err := parseConfig(flag.NewFlagSet("agent", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.OutboxDir != "" {
+		t.Errorf("OutboxDir = %s; want the outbox disabled by default", cfg.OutboxDir)
+	}
+
+	filename := writeConfigFile(t, `{"outbox_dir": "/var/lib/agent/outbox"}`)
+	cfg, err = parseConfig(flag.NewFlagSet("agent", flag.ContinueOnError), []string{"-c", filename})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.OutboxDir != "/var/lib/agent/outbox" {
+		t.Errorf("OutboxDir = %s; want file value", cfg.OutboxDir)
+	}
+}
+
+func TestParseConfig_TLS(t *testing.T) {
+	filename := writeConfigFile(t, `{"tls_ca": "file-ca.crt", "tls_server_name": "file.example.com"}`)
+	t.Setenv("CONFIG", filename)
+	t.Setenv("TLS_SERVER_NAME", "env.example.com")
+
+	cfg, err := parseConfig(flag.NewFlagSet("agent", flag.ContinueOnError), []string{"-tls-cert", "agent.crt", "-tls-key", "agent.key"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.TLSEnabled() {
+		t.Error("expected a CA to enable TLS")
+	}
+	if cfg.TLSCA != "file-ca.crt" || cfg.TLSCert != "agent.crt" || cfg.TLSKey != "agent.key" || cfg.TLSServerName != "env.example.com" {
+		t.Errorf("unexpected TLS options: %+v", cfg)
+	}
+
+	if (&Config{}).TLSEnabled() {
+		t.Error("expected TLS to be disabled by default")
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "duration string", input: `"10s"`, want: 10 * time.Second},
+		{name: "nanoseconds", input: `1000000000`, want: time.Second},
+		{name: "invalid string", input: `"ten seconds"`, wantErr: true},
+		{name: "invalid type", input: `true`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(test.input), &d)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("want error: %v, got: %v", test.wantErr, err)
+			}
+			if !test.wantErr && d.Duration() != test.want {
+				t.Errorf("want: %s, got: %s", test.want, d.Duration())
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loadJSONFile decodes the JSON config file into v. Unknown fields are
+// rejected, so a typo in a field name is not silently ignored.
+func loadJSONFile(filename string, v any) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("config file %s: %w", filename, err)
+	}
+
+	return nil
+}
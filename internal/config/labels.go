@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidLabels = errors.New("labels must be a comma-separated list of name=value pairs")
+
+// ParseLabels parses a "name=value,name=value" list of static metric labels.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, ErrInvalidLabels
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+
+	return labels, nil
+}
+
+// ParseList parses a comma-separated list, skipping empty items.
+func ParseList(s string) []string {
+	var items []string
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr error
+	}{
+		{name: "empty", input: "", want: map[string]string{}},
+		{name: "pairs", input: "env=prod, dc = eu-1", want: map[string]string{"env": "prod", "dc": "eu-1"}},
+		{name: "empty value", input: "role=", want: map[string]string{"role": ""}},
+		{name: "missing value", input: "env", wantErr: ErrInvalidLabels},
+		{name: "missing name", input: "=prod", wantErr: ErrInvalidLabels},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabels(tt.input)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,20 +1,23 @@
 package config
 
-import (
-	"encoding/json"
-	"os"
-	"time"
-)
-
 type ServerConfig struct {
-	Address       string        `json:"address"`
-	Restore       bool          `json:"restore"`
-	StoreInterval time.Duration `json:"store_interval"`
-	StoreFile     string        `json:"store_file"`
-	DatabaseDSN   string        `json:"database_dsn"`
-	CryptoKey     string        `json:"crypto_key"`
-	LogLevel      string        `json:"log_level"`
-	HashKey       string        `json:"hash_key"`
+	Address         string   `json:"address"`
+	Restore         bool     `json:"restore"`
+	StoreInterval   Duration `json:"store_interval"`
+	StoreFile       string   `json:"store_file"`
+	DatabaseDSN     string   `json:"database_dsn"`
+	CryptoKey       string   `json:"crypto_key"`
+	LogLevel        string   `json:"log_level"`
+	HashKey         string   `json:"hash_key"`
+	AlertRules      string   `json:"alert_rules"`
+	TrustedSubnet   string   `json:"trusted_subnet"`
+	TLSCert         string   `json:"tls_cert"`
+	TLSKey          string   `json:"tls_key"`
+	TLSClientCA     string   `json:"tls_client_ca"`
+	TokensFile      string   `json:"tokens_file"`
+	TokensDB        bool     `json:"tokens_db"`
+	MaxClockSkew    Duration `json:"max_clock_skew"`
+	SampleRetention Duration `json:"sample_retention"`
 }
 
 func LoadServerConfig(filename string) (*ServerConfig, error) {
@@ -22,13 +25,8 @@ func LoadServerConfig(filename string) (*ServerConfig, error) {
 		return &ServerConfig{}, nil
 	}
 
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-
 	var config ServerConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := loadJSONFile(filename, &config); err != nil {
 		return nil, err
 	}
 
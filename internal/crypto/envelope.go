@@ -0,0 +1,113 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Values of the Content-Encryption header.
+const (
+	HeaderContentEncryption = "Content-Encryption"
+	EncryptionRSA           = "RSA"       // Legacy: the whole body encrypted with RSA PKCS #1 v1.5
+	EncryptionEnvelopeV1    = "hybrid/v1" // AES-256-GCM body with an RSA-OAEP wrapped key
+)
+
+// envelopeVersion is the first byte of an envelope, so the format can change
+// without breaking older agents.
+const envelopeVersion = 1
+
+const aesKeySize = 32
+
+var (
+	ErrInvalidEnvelope            = errors.New("invalid encrypted envelope")
+	ErrUnsupportedEnvelopeVersion = errors.New("unsupported encrypted envelope version")
+	ErrUnsupportedEncryption      = errors.New("unsupported content encryption")
+)
+
+// EncryptEnvelope encrypts data of any size: the data is sealed with a
+// random AES-256-GCM key, and the key is encrypted with RSA-OAEP (SHA-256).
+//
+// The envelope layout is:
+//
+//	version (1 byte) | wrapped key length (2 bytes, big endian) | wrapped key | nonce | ciphertext
+func EncryptEnvelope(data []byte, publicKey *rsa.PublicKey) ([]byte, error) {
+	key := make([]byte, aesKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	envelope := make([]byte, 0, 3+len(wrappedKey)+len(nonce)+len(data)+gcm.Overhead())
+	envelope = append(envelope, envelopeVersion)
+	envelope = binary.BigEndian.AppendUint16(envelope, uint16(len(wrappedKey)))
+	envelope = append(envelope, wrappedKey...)
+	envelope = append(envelope, nonce...)
+
+	return gcm.Seal(envelope, nonce, data, nil), nil
+}
+
+// DecryptEnvelope decrypts an envelope created by EncryptEnvelope.
+func DecryptEnvelope(envelope []byte, privateKey *rsa.PrivateKey) ([]byte, error) {
+	if len(envelope) < 3 {
+		return nil, ErrInvalidEnvelope
+	}
+	if envelope[0] != envelopeVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedEnvelopeVersion, envelope[0])
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(envelope[1:3]))
+	rest := envelope[3:]
+	if len(rest) < keyLen {
+		return nil, ErrInvalidEnvelope
+	}
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, rest[:keyLen], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+	rest = rest[keyLen:]
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, ErrInvalidEnvelope
+	}
+
+	data, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEnvelope, err)
+	}
+
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
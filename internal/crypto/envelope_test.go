@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	key := generateKey(t)
+	data := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1}`), 1000)
+
+	envelope, err := EncryptEnvelope(data, &key.PublicKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decrypted, err := DecryptEnvelope(envelope, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Error("decrypted data does not match")
+	}
+}
+
+func TestDecryptEnvelope_Invalid(t *testing.T) {
+	key := generateKey(t)
+	envelope, err := EncryptEnvelope([]byte("data"), &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Clone(envelope)
+	tampered[len(tampered)-1] ^= 1
+
+	version := bytes.Clone(envelope)
+	version[0] = 2
+
+	tests := []struct {
+		name     string
+		envelope []byte
+		key      *rsa.PrivateKey
+		wantErr  error
+	}{
+		{name: "tampered", envelope: tampered, key: key, wantErr: ErrInvalidEnvelope},
+		{name: "truncated", envelope: envelope[:100], key: key, wantErr: ErrInvalidEnvelope},
+		{name: "empty", envelope: nil, key: key, wantErr: ErrInvalidEnvelope},
+		{name: "version", envelope: version, key: key, wantErr: ErrUnsupportedEnvelopeVersion},
+		{name: "wrong key", envelope: envelope, key: generateKey(t), wantErr: ErrInvalidEnvelope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecryptEnvelope(tt.envelope, tt.key); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDecryptionMiddleware(t *testing.T) {
+	key := generateKey(t)
+	data := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+
+	envelope, err := EncryptEnvelope(data, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := EncryptData(data, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		encryption string
+		body       []byte
+		want       int
+	}{
+		{name: "envelope", encryption: EncryptionEnvelopeV1, body: envelope, want: http.StatusOK},
+		{name: "legacy", encryption: EncryptionRSA, body: legacy, want: http.StatusOK},
+		{name: "plain", body: data, want: http.StatusOK},
+		{name: "unsupported", encryption: "hybrid/v9", body: envelope, want: http.StatusBadRequest},
+		{name: "mismatch", encryption: EncryptionRSA, body: envelope, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []byte
+			handler := DecryptionMiddleware(NewPrivateKeyHolder(key))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if tt.encryption != "" {
+				req.Header.Set(HeaderContentEncryption, tt.encryption)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rec.Code)
+			}
+			if tt.want == http.StatusOK && !bytes.Equal(received, data) {
+				t.Errorf("unexpected body %q", received)
+			}
+		})
+	}
+}
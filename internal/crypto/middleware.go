@@ -3,24 +3,53 @@ package crypto
 import (
 	"bytes"
 	"crypto/rsa"
+	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 
 	"alerting-service/internal/logger"
 
 	"go.uber.org/zap"
 )
 
+// PrivateKeyHolder holds the server private key, which can be replaced
+// while the server is running.
+type PrivateKeyHolder struct {
+	key atomic.Pointer[rsa.PrivateKey]
+}
+
+// NewPrivateKeyHolder creates a holder with the key; the key may be nil.
+func NewPrivateKeyHolder(privateKey *rsa.PrivateKey) *PrivateKeyHolder {
+	holder := &PrivateKeyHolder{}
+	holder.Store(privateKey)
+	return holder
+}
+
+// Load returns the current key.
+func (h *PrivateKeyHolder) Load() *rsa.PrivateKey {
+	return h.key.Load()
+}
+
+// Store replaces the key.
+func (h *PrivateKeyHolder) Store(privateKey *rsa.PrivateKey) {
+	h.key.Store(privateKey)
+}
+
 // DecryptionMiddleware decrypts incoming requests if they are encrypted.
-func DecryptionMiddleware(privateKey *rsa.PrivateKey) func(http.Handler) http.Handler {
+// Both the envelope scheme and the legacy RSA scheme are accepted, so agents
+// can be upgraded after the server.
+func DecryptionMiddleware(keys *PrivateKeyHolder) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if privateKey == nil || r.Header.Get("Content-Encryption") != "RSA" {
+			privateKey := keys.Load()
+			encryption := r.Header.Get(HeaderContentEncryption)
+			if privateKey == nil || encryption == "" {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			logger.Log.Debug("Decrypting incoming request")
+			logger.Log.Debug("Decrypting incoming request", zap.String("encryption", encryption))
 
 			encryptedData, err := io.ReadAll(r.Body)
 			if err != nil {
@@ -30,7 +59,15 @@ func DecryptionMiddleware(privateKey *rsa.PrivateKey) func(http.Handler) http.Ha
 			}
 			defer r.Body.Close()
 
-			decryptedData, err := DecryptData(encryptedData, privateKey)
+			var decryptedData []byte
+			switch encryption {
+			case EncryptionEnvelopeV1:
+				decryptedData, err = DecryptEnvelope(encryptedData, privateKey)
+			case EncryptionRSA:
+				decryptedData, err = DecryptData(encryptedData, privateKey)
+			default:
+				err = fmt.Errorf("%w: %s", ErrUnsupportedEncryption, encryption)
+			}
 			if err != nil {
 				logger.Log.Error("Failed to decrypt body", zap.Error(err))
 				http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -39,7 +76,7 @@ func DecryptionMiddleware(privateKey *rsa.PrivateKey) func(http.Handler) http.Ha
 
 			r.Body = io.NopCloser(bytes.NewReader(decryptedData))
 			r.ContentLength = int64(len(decryptedData))
-			r.Header.Del("Content-Encryption")
+			r.Header.Del(HeaderContentEncryption)
 
 			next.ServeHTTP(w, r)
 		})
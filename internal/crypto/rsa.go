@@ -14,6 +14,7 @@ var (
 	ErrInvalidKey          = errors.New("invalid key: must be a PEM encoded PKCS1 or PKCS8 key")
 	ErrNotPublicKey        = errors.New("provided key is not a public key")
 	ErrNotPrivateKey       = errors.New("provided key is not a private key")
+	ErrDataTooLarge        = errors.New("data is too large for the RSA key, use EncryptEnvelope")
 )
 
 // LoadPublicKey loads a public key from a file.
@@ -82,11 +83,15 @@ func LoadPrivateKey(filename string) (*rsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
-// EncryptData encrypts data using the provided public key.
+// EncryptData encrypts data using the provided public key. The data must be
+// shorter than the key size minus 11 bytes; use EncryptEnvelope for payloads.
 func EncryptData(data []byte, publicKey *rsa.PublicKey) ([]byte, error) {
 	if publicKey == nil {
 		return data, nil
 	}
+	if len(data) > publicKey.Size()-11 {
+		return nil, ErrDataTooLarge
+	}
 
 	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, publicKey, data)
 	if err != nil {
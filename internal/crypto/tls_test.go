@@ -0,0 +1,130 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert issues a certificate signed by the parent, or a self-signed CA
+// when parent is nil, and writes it with its key to dir.
+func testCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(dir, name+".key"), "EC PRIVATE KEY", keyDER)
+
+	return cert, key
+}
+
+func writePEM(t *testing.T, filename, blockType string, der []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	ca, caKey := testCert(t, dir, "ca", nil, nil, &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign})
+	testCert(t, dir, "server", ca, caKey, &x509.Certificate{DNSNames: []string{"metrics.internal"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}})
+	testCert(t, dir, "agent", ca, caKey, &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+
+	serverConfig, err := ServerTLSConfig(path("server.crt"), path("server.key"), path("ca.crt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		certFile   string
+		keyFile    string
+		serverName string
+		wantErr    bool
+	}{
+		{name: "client certificate", certFile: path("agent.crt"), keyFile: path("agent.key"), serverName: "metrics.internal"},
+		{name: "no client certificate", serverName: "metrics.internal", wantErr: true},
+		{name: "wrong server name", certFile: path("agent.crt"), keyFile: path("agent.key"), serverName: "other.internal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig, err := ClientTLSConfig(path("ca.crt"), tt.certFile, tt.keyFile, tt.serverName)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+			response, err := client.Get(server.URL)
+			if err == nil {
+				response.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestClientTLSConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "empty.crt")
+	if err := os.WriteFile(bundle, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ClientTLSConfig(bundle, "", "", ""); !errors.Is(err, ErrInvalidCABundle) {
+		t.Errorf("expected ErrInvalidCABundle, got %v", err)
+	}
+	if _, err := ClientTLSConfig("", "agent.crt", "", ""); !errors.Is(err, ErrIncompleteKeyPair) {
+		t.Errorf("expected ErrIncompleteKeyPair, got %v", err)
+	}
+	if _, err := ServerTLSConfig("server.crt", "", ""); !errors.Is(err, ErrIncompleteKeyPair) {
+		t.Errorf("expected ErrIncompleteKeyPair, got %v", err)
+	}
+}
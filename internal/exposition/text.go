@@ -0,0 +1,200 @@
+package exposition
+
+import (
+	"alerting-service/internal/models"
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// SanitizeName turns a metric ID into a valid Prometheus metric name
+// matching [a-zA-Z_:][a-zA-Z0-9_:]*. Invalid characters are replaced with
+// underscores and a leading digit is prefixed with one.
+func SanitizeName(id string) string {
+	if id == "" {
+		return "_"
+	}
+
+	name := make([]byte, 0, len(id)+1)
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+			name = append(name, c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				name = append(name, '_')
+			}
+			name = append(name, c)
+		default:
+			name = append(name, '_')
+		}
+	}
+
+	return string(name)
+}
+
+// WriteText renders metrics in the Prometheus text exposition format.
+// Counters get "# TYPE <name> counter" and gauges "# TYPE <name> gauge".
+// Metrics are grouped by sanitized name, so IDs such as a.b and a-b share
+// the a_b family; of series that end up with the same name and labels only
+// the one with the first ID is written. If a gauge and a counter map to the
+// same name, the second one is suffixed with its type so that every name
+// has a single type. The sanitized IDs are claimed first, so a suffix never
+// takes the name of a real metric such as x_gauge.
+func WriteText(w io.Writer, metrics []models.Metrics) error {
+	sorted := make([]models.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		if _, ok := sampleValue(metric); ok {
+			sorted = append(sorted, metric)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ID != sorted[j].ID {
+			return sorted[i].ID < sorted[j].ID
+		}
+		if sorted[i].MType != sorted[j].MType {
+			return sorted[i].MType < sorted[j].MType
+		}
+		return models.LabelsKey(sorted[i].Labels) < models.LabelsKey(sorted[j].Labels)
+	})
+
+	families := map[string]*family{}
+	for _, metric := range sorted {
+		name := SanitizeName(metric.ID)
+		if _, ok := families[name]; !ok {
+			families[name] = newFamily(metric.MType)
+		}
+	}
+
+	for _, metric := range sorted {
+		name := SanitizeName(metric.ID)
+		for families[name] != nil && families[name].mType != metric.MType {
+			name = name + "_" + metric.MType
+		}
+		if families[name] == nil {
+			families[name] = newFamily(metric.MType)
+		}
+
+		value, _ := sampleValue(metric)
+		families[name].add(FormatLabels(metric.Labels), value)
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		if _, err := fmt.Fprintf(buf, "# TYPE %s %s\n", name, f.mType); err != nil {
+			return err
+		}
+		for _, labels := range f.order {
+			if _, err := fmt.Fprintf(buf, "%s%s %s\n", name, labels, formatValue(f.values[labels])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return buf.Flush()
+}
+
+// family is the set of series written under a single metric name.
+type family struct {
+	mType  string
+	order  []string           // Rendered labels in the order they were added
+	values map[string]float64 // Values by rendered labels
+}
+
+func newFamily(mType string) *family {
+	return &family{mType: mType, values: map[string]float64{}}
+}
+
+// add adds a series unless the family already has one with the same labels.
+func (f *family) add(labels string, value float64) {
+	if _, ok := f.values[labels]; ok {
+		return
+	}
+	f.order = append(f.order, labels)
+	f.values[labels] = value
+}
+
+// sampleValue returns the value of the metric, or false if it has none.
+func sampleValue(metric models.Metrics) (float64, bool) {
+	switch {
+	case metric.MType == models.GaugeMetric && metric.Value != nil:
+		return *metric.Value, true
+	case metric.MType == models.CounterMetric && metric.Delta != nil:
+		return float64(*metric.Delta), true
+	default:
+		return 0, false
+	}
+}
+
+// FormatLabels renders labels as {name="value",...} sorted by name, with
+// label names sanitized and values escaped. Names that are equal after
+// sanitizing, such as a.b and a_b, are written once with the value of the
+// name that sorts first. It returns an empty string if there are no labels.
+func FormatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make(map[string]string, len(names))
+	sanitized := make([]string, 0, len(names))
+	for _, name := range names {
+		label := strings.ReplaceAll(SanitizeName(name), ":", "_")
+		if _, ok := values[label]; ok {
+			continue
+		}
+		values[label] = labels[name]
+		sanitized = append(sanitized, label)
+	}
+	sort.Strings(sanitized)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range sanitized {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(values[label]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,115 @@
+package exposition
+
+import (
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
+	"bytes"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "HeapAlloc", want: "HeapAlloc"},
+		{id: "http.requests-total", want: "http_requests_total"},
+		{id: "1xx", want: "_1xx"},
+		{id: "cpu:util", want: "cpu:util"},
+		{id: "", want: "_"},
+	}
+
+	for _, test := range tests {
+		if got := SanitizeName(test.id); got != test.want {
+			t.Errorf("SanitizeName(%q) = %q; want %q", test.id, got, test.want)
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	metrics := []models.Metrics{
+		{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(5)},
+		{ID: "Heap.Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1.5)},
+		{ID: "PollCount", MType: models.GaugeMetric, Value: utils.FloatPtr(2)},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `# TYPE Heap_Alloc gauge
+Heap_Alloc 1.5
+# TYPE PollCount counter
+PollCount 5
+# TYPE PollCount_gauge gauge
+PollCount_gauge 2
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteText_Labels(t *testing.T) {
+	metrics := []models.Metrics{
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(2), Labels: map[string]string{"host": "b"}},
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1), Labels: map[string]string{"host": "a", "path": `C:\"x"`}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `# TYPE Alloc gauge
+Alloc{host="a",path="C:\\\"x\""} 1
+Alloc{host="b"} 2
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestFormatLabels_SanitizedCollisions(t *testing.T) {
+	labels := map[string]string{"a_b": "underscore", "a.b": "dot", "a-b": "dash", "a.a": "first"}
+
+	want := `{a_a="first",a_b="dash"}`
+	for i := 0; i < 10; i++ {
+		if got := FormatLabels(labels); got != want {
+			t.Fatalf("FormatLabels() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestWriteText_SanitizedCollisions(t *testing.T) {
+	metrics := []models.Metrics{
+		{ID: "a-b", MType: models.GaugeMetric, Value: utils.FloatPtr(1)},
+		{ID: "a-c", MType: models.GaugeMetric, Value: utils.FloatPtr(2)},
+		{ID: "a.b", MType: models.GaugeMetric, Value: utils.FloatPtr(3)},
+		{ID: "a.b", MType: models.GaugeMetric, Value: utils.FloatPtr(4), Labels: map[string]string{"host": "a"}},
+		{ID: "x", MType: models.CounterMetric, Delta: utils.IntPtr(5)},
+		{ID: "x", MType: models.GaugeMetric, Value: utils.FloatPtr(6)},
+		{ID: "x_gauge", MType: models.CounterMetric, Delta: utils.IntPtr(7)},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `# TYPE a_b gauge
+a_b 1
+a_b{host="a"} 4
+# TYPE a_c gauge
+a_c 2
+# TYPE x counter
+x 5
+# TYPE x_gauge counter
+x_gauge 7
+# TYPE x_gauge_gauge gauge
+x_gauge_gauge 6
+`
+	if buf.String() != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
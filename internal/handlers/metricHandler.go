@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"alerting-service/internal/auth"
+	"alerting-service/internal/exposition"
 	"alerting-service/internal/logger"
 	"alerting-service/internal/models"
 	"alerting-service/internal/usecases"
@@ -41,8 +43,9 @@ func (handler *metricHandler) UpdateMetric(w http.ResponseWriter, r *http.Reques
 	}
 
 	metric := models.Metrics{
-		ID:    req.ID,
-		MType: req.MType,
+		ID:     req.ID,
+		MType:  req.MType,
+		Labels: req.Labels,
 	}
 
 	if req.MType == models.GaugeMetric {
@@ -51,7 +54,10 @@ func (handler *metricHandler) UpdateMetric(w http.ResponseWriter, r *http.Reques
 		metric.Delta = req.Delta
 	}
 
-	handler.metricUsecase.MetricDataProcessing(metric)
+	if err := handler.metricUsecase.MetricDataProcessing(metric); err != nil {
+		handleError(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -88,8 +94,9 @@ func (handler *metricHandler) GetMetric(w http.ResponseWriter, r *http.Request)
 	}
 
 	metric := models.Metrics{
-		ID:    req.ID,
-		MType: req.MType,
+		ID:     req.ID,
+		MType:  req.MType,
+		Labels: req.Labels,
 	}
 
 	value, err := handler.metricUsecase.GetMetricDataProcessing(metric)
@@ -163,13 +170,14 @@ func (handler *metricHandler) GetURLMetric(w http.ResponseWriter, req *http.Requ
 }
 
 // GetAllMetrics handles a GET request and returns all stored metrics as HTML text.
+// Series can be filtered with label=<name>=<value> query parameters.
 func (handler *metricHandler) GetAllMetrics(w http.ResponseWriter, req *http.Request) {
 	if req.Method != http.MethodGet {
 		handleError(w, v.ErrMethodNotAllowed)
 		return
 	}
 
-	allMetrics, err := handler.metricUsecase.GetMetrics()
+	allMetrics, err := handler.findMetrics(req)
 	if err != nil {
 		handleError(w, err)
 		return
@@ -180,14 +188,38 @@ func (handler *metricHandler) GetAllMetrics(w http.ResponseWriter, req *http.Req
 
 	for _, metric := range allMetrics {
 		mType := metric.MType
+		name := metric.ID + exposition.FormatLabels(metric.Labels)
 		if mType == models.GaugeMetric {
-			w.Write([]byte(fmt.Sprintf("%s: %f\n", metric.ID, *metric.Value)))
+			w.Write([]byte(fmt.Sprintf("%s: %f\n", name, *metric.Value)))
 		} else {
-			w.Write([]byte(fmt.Sprintf("%s: %d\n", metric.ID, *metric.Delta)))
+			w.Write([]byte(fmt.Sprintf("%s: %d\n", name, *metric.Delta)))
 		}
 	}
 }
 
+// GetPrometheusMetrics handles a GET request and returns all stored metrics
+// in the Prometheus text exposition format. Series can be filtered with
+// label=<name>=<value> query parameters.
+func (handler *metricHandler) GetPrometheusMetrics(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		handleError(w, v.ErrMethodNotAllowed)
+		return
+	}
+
+	allMetrics, err := handler.findMetrics(req)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", exposition.ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	if err := exposition.WriteText(w, allMetrics); err != nil {
+		logger.Log.Debug("error writing metrics", zap.Error(err))
+	}
+}
+
 // UpdateMetrics handles a POST request to update multiple metrics provided in a JSON array.
 func (handler *metricHandler) UpdateMetrics(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -221,6 +253,62 @@ func (handler *metricHandler) UpdateMetrics(w http.ResponseWriter, r *http.Reque
 	logger.Log.Debug("Successfully processed batch update, sending HTTP 200 response")
 }
 
+// QueryRange handles a GET request for the aggregated history of a metric.
+func (handler *metricHandler) QueryRange(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		handleError(w, v.ErrMethodNotAllowed)
+		return
+	}
+
+	query, err := utils.ParseRangeQuery(req.URL.Query())
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	points, err := handler.metricUsecase.QueryRange(query)
+	if err != nil {
+		handleError(w, err)
+		return
+	}
+
+	result := models.RangeResult{
+		ID:          query.ID,
+		MType:       query.MType,
+		Labels:      query.Labels,
+		Aggregation: query.Aggregation,
+		Step:        query.Step.String(),
+		Points:      points,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(result); err != nil {
+		logger.Log.Debug("error encoding response", zap.Error(err))
+		return
+	}
+	logger.Log.Debug("sending HTTP 200 response")
+}
+
+func (handler *metricHandler) findMetrics(req *http.Request) ([]models.Metrics, error) {
+	labels, err := utils.ParseLabelFilter(req.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []models.Metrics
+	if len(labels) == 0 {
+		metrics, err = handler.metricUsecase.GetMetrics()
+	} else {
+		metrics, err = handler.metricUsecase.FindMetrics(labels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return auth.FilterMetrics(req.Context(), metrics), nil
+}
+
 func handleError(w http.ResponseWriter, err error) {
 	statusCode, ok := v.ErrMap[err]
 
@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"alerting-service/internal/auth"
 	"alerting-service/internal/models"
 	repository "alerting-service/internal/repository"
 	"alerting-service/internal/usecases"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -178,3 +183,165 @@ func floatPtr(v float64) *float64 {
 func int64Ptr(v int64) *int64 {
 	return &v
 }
+
+func TestQueryRange(t *testing.T) {
+	handler := NewMetricHandler(usecases.NewMetricUsecase(repository.NewMemStorageRepository()))
+	handler.metricUsecase.MetricDataProcessing(models.Metrics{MType: "gauge", ID: "load", Value: floatPtr(1.5)})
+	handler.metricUsecase.MetricDataProcessing(models.Metrics{MType: "gauge", ID: "load", Value: floatPtr(2.5)})
+
+	now := time.Now()
+	target := fmt.Sprintf("/query_range?id=load&type=gauge&start=%d&end=%d&step=1h&agg=max", now.Add(-time.Hour).Unix(), now.Add(time.Hour).Unix())
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	w := httptest.NewRecorder()
+
+	handler.QueryRange(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var got models.RangeResult
+	err := json.NewDecoder(res.Body).Decode(&got)
+	assert.NoError(t, err)
+	assert.Equal(t, "max", got.Aggregation)
+	if assert.NotEmpty(t, got.Points) {
+		assert.Equal(t, 2.5, got.Points[len(got.Points)-1].Value)
+	}
+}
+
+func TestQueryRange_BadRequest(t *testing.T) {
+	handler := NewMetricHandler(usecases.NewMetricUsecase(repository.NewMemStorageRepository()))
+
+	req := httptest.NewRequest(http.MethodGet, "/query_range?id=load&type=gauge", nil)
+	w := httptest.NewRecorder()
+
+	handler.QueryRange(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestGetPrometheusMetrics(t *testing.T) {
+	handler := NewMetricHandler(usecases.NewMetricUsecase(repository.NewMemStorageRepository()))
+	handler.metricUsecase.MetricDataProcessing(models.Metrics{MType: "gauge", ID: "cpu", Value: floatPtr(70.5)})
+	handler.metricUsecase.MetricDataProcessing(models.Metrics{MType: "counter", ID: "hits", Delta: int64Ptr(5)})
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	handler.GetPrometheusMetrics(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "# TYPE cpu gauge\ncpu 70.5\n")
+	assert.Contains(t, string(body), "# TYPE hits counter\nhits 5\n")
+}
+
+func TestLabeledMetrics(t *testing.T) {
+	handler := NewMetricHandler(usecases.NewMetricUsecase(repository.NewMemStorageRepository()))
+
+	for _, body := range []string{
+		`{"id":"Alloc","type":"gauge","value":1,"labels":{"host":"a"}}`,
+		`{"id":"Alloc","type":"gauge","value":2,"labels":{"host":"b"}}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/update", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.UpdateMetric(w, req)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		w.Result().Body.Close()
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/value/", strings.NewReader(`{"id":"Alloc","type":"gauge","labels":{"host":"b"}}`))
+	w := httptest.NewRecorder()
+	handler.GetMetric(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+
+	var got models.Metrics
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&got))
+	assert.Equal(t, 2.0, *got.Value)
+	assert.Equal(t, "b", got.Labels["host"])
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics?label=host=a", nil)
+	w = httptest.NewRecorder()
+	handler.GetPrometheusMetrics(w, req)
+
+	body, _ := io.ReadAll(w.Result().Body)
+	assert.Equal(t, "# TYPE Alloc gauge\nAlloc{host=\"a\"} 1\n", string(body))
+}
+
+func TestReadRoutes_TokenPrefixes(t *testing.T) {
+	handler := NewMetricHandler(usecases.NewMetricUsecase(repository.NewMemStorageRepository()))
+	handler.metricUsecase.MetricDataProcessing(models.Metrics{MType: "gauge", ID: "web_latency", Value: floatPtr(0.5)})
+	handler.metricUsecase.MetricDataProcessing(models.Metrics{MType: "counter", ID: "db_queries", Delta: int64Ptr(5)})
+
+	store := auth.NewFileStore([]auth.Token{
+		{Name: "web", Hash: auth.HashToken("web-reader"), Scope: auth.ScopeRead, Prefixes: []string{"web_"}},
+	})
+
+	r := chi.NewRouter()
+	r.Route("/query_range", func(r chi.Router) {
+		r.Use(auth.Middleware(store, auth.ScopeRead, auth.QueryParam("id")))
+		r.Get("/", handler.QueryRange)
+	})
+	r.Route("/metrics", func(r chi.Router) {
+		r.Use(auth.Middleware(store, auth.ScopeRead, nil))
+		r.Get("/", handler.GetPrometheusMetrics)
+	})
+	r.Route("/", func(r chi.Router) {
+		r.Use(auth.Middleware(store, auth.ScopeRead, nil))
+		r.Get("/", handler.GetAllMetrics)
+	})
+
+	now := time.Now()
+	queryRange := func(id string) string {
+		return fmt.Sprintf("/query_range?id=%s&type=gauge&start=%d&end=%d&step=1h", id, now.Add(-time.Hour).Unix(), now.Add(time.Hour).Unix())
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		want     int
+		contains string
+		excludes string
+	}{
+		{name: "list without token", path: "/", want: http.StatusUnauthorized},
+		{name: "metrics without token", path: "/metrics", want: http.StatusUnauthorized},
+		{name: "range without token", path: queryRange("web_latency"), want: http.StatusUnauthorized},
+		{name: "list filtered", path: "/", token: "web-reader", want: http.StatusOK, contains: "web_latency", excludes: "db_queries"},
+		{name: "metrics filtered", path: "/metrics", token: "web-reader", want: http.StatusOK, contains: "web_latency", excludes: "db_queries"},
+		{name: "range allowed", path: queryRange("web_latency"), token: "web-reader", want: http.StatusOK},
+		{name: "range outside prefixes", path: queryRange("db_queries"), token: "web-reader", want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tt.want, res.StatusCode)
+			if tt.contains != "" {
+				assert.Contains(t, string(body), tt.contains)
+			}
+			if tt.excludes != "" {
+				assert.NotContains(t, string(body), tt.excludes)
+			}
+		})
+	}
+}
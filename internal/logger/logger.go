@@ -6,10 +6,15 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var Log *zap.Logger = zap.NewNop()
 
+// level is shared by every logger built by Initialize, so SetLevel takes
+// effect without rebuilding the logger.
+var level = zap.NewAtomicLevel()
+
 type loggerResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -34,14 +39,15 @@ func (lrw *loggerResponseWriter) Write(body []byte) (int, error) {
 	return lrw.size, err
 }
 
-func Initialize(level string) error {
-	lvl, err := zap.ParseAtomicLevel(level)
+func Initialize(logLevel string) error {
+	lvl, err := zapcore.ParseLevel(logLevel)
 	if err != nil {
 		return err
 	}
+	level.SetLevel(lvl)
 
 	cfg := zap.NewProductionConfig()
-	cfg.Level = lvl
+	cfg.Level = level
 
 	zl, err := cfg.Build()
 	if err != nil {
@@ -52,6 +58,16 @@ func Initialize(level string) error {
 	return nil
 }
 
+// SetLevel changes the level of the running logger.
+func SetLevel(lvl zapcore.Level) {
+	level.SetLevel(lvl)
+}
+
+// Level returns the current log level.
+func Level() zapcore.Level {
+	return level.Level()
+}
+
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
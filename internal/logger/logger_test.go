@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestLoggerResponseWriter_WriteHeaderAndWrite(t *testing.T) {
@@ -71,3 +74,19 @@ func TestResponseLogger(t *testing.T) {
 		t.Errorf("expected body 'accepted', got %s", string(body))
 	}
 }
+
+func TestSetLevel(t *testing.T) {
+	if err := Initialize("info"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { Log = zap.NewNop() }()
+
+	if Log.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug enabled at info level")
+	}
+
+	SetLevel(zapcore.DebugLevel)
+	if !Log.Core().Enabled(zapcore.DebugLevel) || Level() != zapcore.DebugLevel {
+		t.Error("expected debug to be enabled without rebuilding the logger")
+	}
+}
@@ -6,11 +6,41 @@ func InitDB(db *sql.DB) error {
 	schema := `
     CREATE TABLE IF NOT EXISTS metrics (
         id SERIAL PRIMARY KEY,
-        name TEXT NOT NULL UNIQUE,
+        name TEXT NOT NULL,
         type TEXT CHECK (type IN ('gauge', 'counter')) NOT NULL,
         value DOUBLE PRECISION,
         delta BIGINT,
+        labels JSONB NOT NULL DEFAULT '{}'::jsonb,
         updated_at TIMESTAMP DEFAULT NOW()
+    );
+
+    ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+    ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_name_key;
+    CREATE UNIQUE INDEX IF NOT EXISTS metrics_name_labels_key ON metrics (name, labels);
+
+    CREATE TABLE IF NOT EXISTS metric_samples (
+        id BIGSERIAL PRIMARY KEY,
+        name TEXT NOT NULL,
+        type TEXT CHECK (type IN ('gauge', 'counter')) NOT NULL,
+        value DOUBLE PRECISION NOT NULL,
+        delta BIGINT,
+        labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+
+    ALTER TABLE metric_samples ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+    DROP INDEX IF EXISTS metric_samples_name_type_created_at_idx;
+    CREATE INDEX IF NOT EXISTS metric_samples_series_created_at_idx
+        ON metric_samples (name, type, labels, created_at);
+    CREATE INDEX IF NOT EXISTS metric_samples_created_at_idx ON metric_samples (created_at);
+
+    CREATE TABLE IF NOT EXISTS api_tokens (
+        name TEXT PRIMARY KEY,
+        token_sha256 TEXT NOT NULL UNIQUE,
+        scope TEXT CHECK (scope IN ('read', 'write', 'admin')) NOT NULL,
+        prefixes JSONB NOT NULL DEFAULT '[]'::jsonb,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        revoked_at TIMESTAMPTZ
     );`
 	_, err := db.Exec(schema)
 	return err
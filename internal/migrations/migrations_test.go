@@ -21,6 +21,7 @@ func TestInitDB(t *testing.T) {
 	defer db.Close()
 
 	_, _ = db.Exec("DROP TABLE IF EXISTS metrics")
+	_, _ = db.Exec("DROP TABLE IF EXISTS metric_samples")
 
 	if err := InitDB(db); err != nil {
 		t.Fatalf("InitDB returned error: %v", err)
@@ -46,4 +47,12 @@ func TestInitDB(t *testing.T) {
 	if err := rows.Err(); err != nil {
 		t.Errorf("rows iteration error: %v", err)
 	}
+
+	if _, err := db.Exec("SELECT id, name, type, value, delta, created_at FROM metric_samples"); err != nil {
+		t.Errorf("table 'metric_samples' does not exist or query failed: %v", err)
+	}
+
+	if _, err := db.Exec("SELECT name, token_sha256, scope, prefixes, revoked_at FROM api_tokens"); err != nil {
+		t.Errorf("table 'api_tokens' does not exist or query failed: %v", err)
+	}
 }
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // CounterMetric is the type identifier for counter metrics.
 const CounterMetric = "counter"
 
@@ -8,8 +10,61 @@ const GaugeMetric = "gauge"
 
 // Metrics defines a data structure representing a single metric.
 type Metrics struct {
-	ID    string   `json:"id"`              // Unique metric identifier
-	MType string   `json:"type"`            // Metric type: "gauge" or "counter"
-	Delta *int64   `json:"delta,omitempty"` // Metric value for counter type
-	Value *float64 `json:"value,omitempty"` // Metric value for gauge type
+	ID     string            `json:"id"`               // Unique metric identifier
+	MType  string            `json:"type"`             // Metric type: "gauge" or "counter"
+	Delta  *int64            `json:"delta,omitempty"`  // Metric value for counter type
+	Value  *float64          `json:"value,omitempty"`  // Metric value for gauge type
+	Labels map[string]string `json:"labels,omitempty"` // Optional labels, part of the series identity
+}
+
+// MetricFilter selects series; empty fields match any series and labels
+// match every series that has at least the given label values.
+type MetricFilter struct {
+	ID     string            // Metric identifier
+	MType  string            // Metric type: "gauge" or "counter"
+	Labels map[string]string // Required label values
+}
+
+// Matches reports whether the metric is selected by the filter.
+func (f MetricFilter) Matches(metric Metrics) bool {
+	if f.ID != "" && f.ID != metric.ID {
+		return false
+	}
+	if f.MType != "" && f.MType != metric.MType {
+		return false
+	}
+	return HasLabels(metric.Labels, f.Labels)
+}
+
+// HasLabels reports whether labels contain every name/value pair of subset.
+func HasLabels(labels, subset map[string]string) bool {
+	for name, value := range subset {
+		if actual, ok := labels[name]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// LabelsKey returns a canonical encoding of the labels that can be used as
+// part of a map key: a JSON object with sorted keys, or an empty string if
+// there are no labels.
+func LabelsKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	data, _ := json.Marshal(labels)
+	return string(data)
+}
+
+// ParseLabelsKey decodes labels encoded with LabelsKey.
+func ParseLabelsKey(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+
+	var labels map[string]string
+	_ = json.Unmarshal([]byte(key), &labels)
+	return labels
 }
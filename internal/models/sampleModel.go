@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Sample is a single time-stamped value of a metric.
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"`       // Time the update was accepted
+	Value     float64   `json:"value"`           // Gauge value or counter total after the update
+	Delta     int64     `json:"delta,omitempty"` // Counter increment carried by the update
+}
+
+// SampleQuery selects the samples of a series within a time range.
+type SampleQuery struct {
+	ID     string            // Metric identifier
+	MType  string            // Metric type: "gauge" or "counter"
+	Labels map[string]string // Exact labels of the series
+	Start  time.Time         // Inclusive lower bound; zero means unbounded
+	End    time.Time         // Inclusive upper bound; zero means unbounded
+}
+
+// Contains reports whether the timestamp falls within the query range.
+func (q SampleQuery) Contains(ts time.Time) bool {
+	if !q.Start.IsZero() && ts.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && ts.After(q.End) {
+		return false
+	}
+	return true
+}
+
+// Aggregations supported by range queries.
+const (
+	AggregationAvg  = "avg"
+	AggregationMin  = "min"
+	AggregationMax  = "max"
+	AggregationLast = "last"
+	AggregationSum  = "sum"
+)
+
+// RangeQuery requests the history of a metric aggregated into fixed steps.
+type RangeQuery struct {
+	ID          string            // Metric identifier
+	MType       string            // Metric type: "gauge" or "counter"
+	Labels      map[string]string // Exact labels of the series
+	Start       time.Time         // Inclusive lower bound
+	End         time.Time         // Inclusive upper bound
+	Step        time.Duration     // Width of a single point
+	Aggregation string            // How samples within a step are combined
+}
+
+// Point is an aggregated value of a metric for the step starting at Timestamp.
+type Point struct {
+	Timestamp time.Time `json:"t"`
+	Value     float64   `json:"v"`
+}
+
+// RangeResult is the response of a range query.
+type RangeResult struct {
+	ID          string            `json:"id"`
+	MType       string            `json:"type"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Aggregation string            `json:"agg"`
+	Step        string            `json:"step"`
+	Points      []Point           `json:"points"`
+}
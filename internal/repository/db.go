@@ -5,6 +5,7 @@ import (
 	"alerting-service/internal/models"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -24,7 +25,7 @@ func NewDBStorageRepository(db *sql.DB) StorageRepository {
 func (d *DBStorageImp) GetCounterMetric(key string) (int, bool, error) {
 	var delta int
 
-	row := d.db.QueryRow("SELECT delta FROM metrics WHERE type = 'counter' AND name = $1", key)
+	row := d.db.QueryRow("SELECT delta FROM metrics WHERE type = 'counter' AND name = $1 AND labels = '{}'::jsonb", key)
 	err := row.Scan(&delta)
 	if err != nil {
 		return 0, false, err
@@ -36,7 +37,7 @@ func (d *DBStorageImp) GetCounterMetric(key string) (int, bool, error) {
 func (d *DBStorageImp) GetGaugeMetric(key string) (float64, bool, error) {
 	var value float64
 
-	row := d.db.QueryRow("SELECT value FROM metrics WHERE type = 'gauge' AND name = $1", key)
+	row := d.db.QueryRow("SELECT value FROM metrics WHERE type = 'gauge' AND name = $1 AND labels = '{}'::jsonb", key)
 	err := row.Scan(&value)
 
 	if err != nil {
@@ -48,10 +49,14 @@ func (d *DBStorageImp) GetGaugeMetric(key string) (float64, bool, error) {
 
 func (d *DBStorageImp) UpdateGaugeMetric(metricName string, value float64) error {
 	query := `
+WITH updated AS (
 INSERT INTO metrics (name, type, value, delta)
 VALUES ($1, $2, $3, NULL)
-ON CONFLICT (name) DO UPDATE
-SET value = EXCLUDED.value, updated_at = NOW();`
+ON CONFLICT (name, labels) DO UPDATE
+SET value = EXCLUDED.value, updated_at = NOW()
+RETURNING name, type, value, labels)
+INSERT INTO metric_samples (name, type, value, labels)
+SELECT name, type, value, labels FROM updated;`
 
 	stmt, err := d.db.PrepareContext(context.Background(), query)
 	if err != nil {
@@ -65,10 +70,14 @@ SET value = EXCLUDED.value, updated_at = NOW();`
 
 func (d *DBStorageImp) UpdateCounterMetric(metricName string, value int) error {
 	query := `
+WITH updated AS (
 INSERT INTO metrics (name, type, value, delta)
 VALUES ($1, $2, $3, $4)
-ON CONFLICT (name) DO UPDATE
-SET delta = metrics.delta + EXCLUDED.delta, updated_at = NOW();`
+ON CONFLICT (name, labels) DO UPDATE
+SET delta = metrics.delta + EXCLUDED.delta, updated_at = NOW()
+RETURNING name, type, delta, labels)
+INSERT INTO metric_samples (name, type, value, delta, labels)
+SELECT name, type, delta, $4, labels FROM updated;`
 
 	stmt, err := d.db.PrepareContext(context.Background(), query)
 	if err != nil {
@@ -82,7 +91,14 @@ SET delta = metrics.delta + EXCLUDED.delta, updated_at = NOW();`
 }
 
 func (d *DBStorageImp) GetMetrics() ([]models.Metrics, error) {
-	rows, err := d.db.Query("SELECT name, type, value, delta FROM metrics")
+	return d.FindMetrics(models.MetricFilter{})
+}
+
+func (d *DBStorageImp) FindMetrics(filter models.MetricFilter) ([]models.Metrics, error) {
+	rows, err := d.db.Query(`
+SELECT name, type, value, delta, labels FROM metrics
+WHERE ($1 = '' OR name = $1) AND ($2 = '' OR type = $2) AND labels @> $3::jsonb`,
+		filter.ID, filter.MType, labelsJSON(filter.Labels))
 	if err != nil {
 		return nil, err
 	}
@@ -91,23 +107,11 @@ func (d *DBStorageImp) GetMetrics() ([]models.Metrics, error) {
 	var allMetrics []models.Metrics
 
 	for rows.Next() {
-		var metric models.Metrics
-		var value sql.NullFloat64
-		var delta sql.NullInt64
-
-		err := rows.Scan(&metric.ID, &metric.MType, &value, &delta)
+		metric, err := scanMetric(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		if value.Valid {
-			metric.Value = &value.Float64
-		}
-		if delta.Valid {
-			deltaValue := delta.Int64
-			metric.Delta = &deltaValue
-		}
-
 		allMetrics = append(allMetrics, metric)
 	}
 
@@ -118,6 +122,21 @@ func (d *DBStorageImp) GetMetrics() ([]models.Metrics, error) {
 	return allMetrics, nil
 }
 
+func (d *DBStorageImp) GetSeries(id, mType string, labels map[string]string) (models.Metrics, bool, error) {
+	row := d.db.QueryRow("SELECT name, type, value, delta, labels FROM metrics WHERE name = $1 AND type = $2 AND labels = $3::jsonb",
+		id, mType, labelsJSON(labels))
+
+	metric, err := scanMetric(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Metrics{}, false, nil
+	}
+	if err != nil {
+		return models.Metrics{}, false, err
+	}
+
+	return metric, true, nil
+}
+
 func (d *DBStorageImp) SetMetrics(allMetrics []models.Metrics) {
 
 }
@@ -139,13 +158,18 @@ func (d *DBStorageImp) UpdateMetrics(metrics []models.Metrics) error {
 		}
 	}()
 
-	query := `INSERT INTO metrics (name, type, value, delta) 
-              VALUES ($1, $2, $3, $4) 
-              ON CONFLICT (name) DO UPDATE 
+	query := `WITH updated AS (
+              INSERT INTO metrics (name, type, value, delta, labels) 
+              VALUES ($1, $2, $3, $4, $5::jsonb) 
+              ON CONFLICT (name, labels) DO UPDATE 
               SET delta = COALESCE(metrics.delta, 0) + COALESCE(EXCLUDED.delta, 0), 
-                  value = COALESCE(EXCLUDED.value, metrics.value);`
+                  value = COALESCE(EXCLUDED.value, metrics.value)
+              RETURNING name, type, value, delta, labels)
+              INSERT INTO metric_samples (name, type, value, delta, labels)
+              SELECT name, type, CASE WHEN type = 'counter' THEN delta ELSE value END, $4, labels
+              FROM updated;`
 
-	stmt, err := d.db.PrepareContext(context.Background(), query)
+	stmt, err := tx.PrepareContext(context.Background(), query)
 	if err != nil {
 		return err
 	}
@@ -163,7 +187,7 @@ func (d *DBStorageImp) UpdateMetrics(metrics []models.Metrics) error {
 			delta = metric.Delta
 		}
 
-		_, err := d.retryExecute(context.Background(), stmt, metric.ID, metric.MType, value, delta)
+		_, err = d.retryExecute(context.Background(), stmt, metric.ID, metric.MType, value, delta, labelsJSON(metric.Labels))
 		if err != nil {
 			logger.Log.Error("Error executing SQL query", zap.String("metric_id", metric.ID), zap.Error(err))
 			return err
@@ -180,6 +204,44 @@ func (d *DBStorageImp) UpdateMetrics(metrics []models.Metrics) error {
 	return nil
 }
 
+// DeleteSamples deletes the samples recorded before the time.
+func (d *DBStorageImp) DeleteSamples(before time.Time) (int64, error) {
+	result, err := d.db.Exec("DELETE FROM metric_samples WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (d *DBStorageImp) GetSamples(query models.SampleQuery) ([]models.Sample, error) {
+	rows, err := d.db.Query(`
+SELECT created_at, value, COALESCE(delta, 0) FROM metric_samples
+WHERE name = $1 AND type = $2 AND labels = $5::jsonb
+  AND ($3::timestamptz IS NULL OR created_at >= $3)
+  AND ($4::timestamptz IS NULL OR created_at <= $4)
+ORDER BY created_at, id`, query.ID, query.MType, nullTime(query.Start), nullTime(query.End), labelsJSON(query.Labels))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []models.Sample{}
+
+	for rows.Next() {
+		var sample models.Sample
+		if err := rows.Scan(&sample.Timestamp, &sample.Value, &sample.Delta); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
 func (d *DBStorageImp) PingContext(ctx context.Context) error {
 	return d.db.PingContext(ctx)
 }
@@ -213,6 +275,49 @@ func (d *DBStorageImp) retryExecute(ctx context.Context, stmt *sql.Stmt, args ..
 	return nil, err
 }
 
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMetric(row rowScanner) (models.Metrics, error) {
+	var metric models.Metrics
+	var value sql.NullFloat64
+	var delta sql.NullInt64
+	var labels []byte
+
+	if err := row.Scan(&metric.ID, &metric.MType, &value, &delta, &labels); err != nil {
+		return models.Metrics{}, err
+	}
+
+	if value.Valid {
+		metric.Value = &value.Float64
+	}
+	if delta.Valid {
+		deltaValue := delta.Int64
+		metric.Delta = &deltaValue
+	}
+	if err := json.Unmarshal(labels, &metric.Labels); err != nil {
+		return models.Metrics{}, err
+	}
+	if len(metric.Labels) == 0 {
+		metric.Labels = nil
+	}
+
+	return metric, nil
+}
+
+func labelsJSON(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "{}"
+	}
+
+	return models.LabelsKey(labels)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
 func isRetriableError(err error) bool {
 	var pqErr *pgconn.PgError
 	if errors.As(err, &pqErr) {
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"alerting-service/internal/models"
+	"alerting-service/internal/utils"
 	"database/sql"
 	"os"
 	"testing"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
@@ -18,14 +21,27 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("failed to open db: %v", err)
 	}
 	_, _ = db.Exec("DROP TABLE IF EXISTS metrics")
+	_, _ = db.Exec("DROP TABLE IF EXISTS metric_samples")
 	_, _ = db.Exec(`
 	CREATE TABLE metrics (
 		id SERIAL PRIMARY KEY,
-		name TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
 		type TEXT CHECK (type IN ('gauge', 'counter')) NOT NULL,
 		value DOUBLE PRECISION,
 		delta BIGINT,
-		updated_at TIMESTAMP DEFAULT NOW()
+		labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+		updated_at TIMESTAMP DEFAULT NOW(),
+		UNIQUE (name, labels)
+	)`)
+	_, _ = db.Exec(`
+	CREATE TABLE metric_samples (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		type TEXT CHECK (type IN ('gauge', 'counter')) NOT NULL,
+		value DOUBLE PRECISION NOT NULL,
+		delta BIGINT,
+		labels JSONB NOT NULL DEFAULT '{}'::jsonb,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 	)`)
 	return db
 }
@@ -91,3 +107,84 @@ func TestDBStorage_GetMetrics(t *testing.T) {
 		t.Errorf("expected 2 metrics, got %d", len(metrics))
 	}
 }
+
+func TestDBStorage_GetSamples(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewDBStorageRepository(db)
+
+	_ = repo.UpdateCounterMetric("c1", 3)
+	_ = repo.UpdateCounterMetric("c1", 4)
+
+	samples, err := repo.GetSamples(models.SampleQuery{ID: "c1", MType: models.CounterMetric})
+	if err != nil {
+		t.Fatalf("get samples failed: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+	if samples[1].Value != 7 || samples[1].Delta != 4 {
+		t.Errorf("unexpected sample: %+v", samples[1])
+	}
+}
+
+func TestDBStorage_DeleteSamples(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewDBStorageRepository(db)
+
+	_ = repo.UpdateGaugeMetric("g1", 1)
+	_ = repo.UpdateGaugeMetric("g1", 2)
+	if _, err := db.Exec("UPDATE metric_samples SET created_at = NOW() - INTERVAL '2 hours' WHERE value = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := repo.DeleteSamples(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("delete samples failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 deleted sample, got %d", deleted)
+	}
+
+	samples, err := repo.GetSamples(models.SampleQuery{ID: "g1", MType: models.GaugeMetric})
+	if err != nil {
+		t.Fatalf("get samples failed: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Value != 2 {
+		t.Errorf("expected only the recent sample, got %+v", samples)
+	}
+}
+
+func TestDBStorage_Labels(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	repo := NewDBStorageRepository(db)
+
+	err := repo.UpdateMetrics([]models.Metrics{
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1), Labels: map[string]string{"host": "a"}},
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(2), Labels: map[string]string{"host": "b"}},
+	})
+	if err != nil {
+		t.Fatalf("update metrics failed: %v", err)
+	}
+
+	metric, ok, err := repo.GetSeries("Alloc", models.GaugeMetric, map[string]string{"host": "b"})
+	if err != nil || !ok {
+		t.Fatalf("get series failed: %v", err)
+	}
+	if *metric.Value != 2 {
+		t.Errorf("expected 2, got %f", *metric.Value)
+	}
+
+	metrics, err := repo.FindMetrics(models.MetricFilter{Labels: map[string]string{"host": "a"}})
+	if err != nil {
+		t.Fatalf("find metrics failed: %v", err)
+	}
+	if len(metrics) != 1 || *metrics[0].Value != 1 {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+}
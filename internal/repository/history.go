@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"alerting-service/internal/models"
+	"time"
+)
+
+// DefaultHistorySize is the number of samples kept per metric in memory.
+const DefaultHistorySize = 1024
+
+// DefaultSampleRetention is how long samples are kept before they are
+// deleted.
+const DefaultSampleRetention = 7 * 24 * time.Hour
+
+// seriesKey identifies a series by metric ID and canonical labels.
+type seriesKey struct {
+	id     string
+	labels string
+}
+
+func newSeriesKey(id string, labels map[string]string) seriesKey {
+	return seriesKey{id: id, labels: models.LabelsKey(labels)}
+}
+
+type historyKey struct {
+	mType  string
+	series seriesKey
+}
+
+// sampleRing is a ring buffer of samples that grows as samples arrive, up
+// to its size; when it is full the oldest sample is overwritten.
+type sampleRing struct {
+	samples []models.Sample
+	size    int
+	start   int // Index of the oldest sample
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{size: size}
+}
+
+func (r *sampleRing) add(sample models.Sample) {
+	if len(r.samples) < r.size {
+		r.samples = append(r.samples, sample)
+		return
+	}
+
+	r.samples[r.start] = sample
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+// ordered returns the samples, oldest first.
+func (r *sampleRing) ordered() []models.Sample {
+	if r.start == 0 {
+		return r.samples
+	}
+	return append(append([]models.Sample{}, r.samples[r.start:]...), r.samples[:r.start]...)
+}
+
+// prune removes the samples recorded before the time and returns how many
+// were removed.
+func (r *sampleRing) prune(before time.Time) int {
+	ordered := r.ordered()
+
+	removed := 0
+	for removed < len(ordered) && ordered[removed].Timestamp.Before(before) {
+		removed++
+	}
+	if removed == 0 {
+		return 0
+	}
+
+	r.samples = append([]models.Sample(nil), ordered[removed:]...)
+	r.start = 0
+	return removed
+}
+
+// list returns the samples matching the query, oldest first.
+func (r *sampleRing) list(query models.SampleQuery) []models.Sample {
+	result := []models.Sample{}
+	for _, sample := range r.ordered() {
+		if query.Contains(sample.Timestamp) {
+			result = append(result, sample)
+		}
+	}
+
+	return result
+}
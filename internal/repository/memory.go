@@ -3,20 +3,22 @@ package repository
 import (
 	"alerting-service/internal/models"
 	"sync"
+	"time"
 )
 
 type MemStorageImp struct {
-	gauges   map[string]float64
-	counters map[string]int
+	gauges   map[seriesKey]float64
+	counters map[seriesKey]int
+	history  map[historyKey]*sampleRing
 	mu       sync.Mutex
 }
 
 func NewMemStorageRepository() StorageRepository {
-	return &MemStorageImp{gauges: map[string]float64{}, counters: map[string]int{}}
+	return &MemStorageImp{gauges: map[seriesKey]float64{}, counters: map[seriesKey]int{}, history: map[historyKey]*sampleRing{}}
 }
 
 func (s *MemStorageImp) GetCounterMetric(key string) (int, bool, error) {
-	if counter, ok := s.counters[key]; !ok {
+	if counter, ok := s.counters[seriesKey{id: key}]; !ok {
 		return 0, ok, nil
 	} else {
 		return counter, ok, nil
@@ -24,7 +26,7 @@ func (s *MemStorageImp) GetCounterMetric(key string) (int, bool, error) {
 }
 
 func (s *MemStorageImp) GetGaugeMetric(key string) (float64, bool, error) {
-	if gauge, ok := s.gauges[key]; !ok {
+	if gauge, ok := s.gauges[seriesKey{id: key}]; !ok {
 		return 0.0, ok, nil
 	} else {
 		return gauge, ok, nil
@@ -33,52 +35,98 @@ func (s *MemStorageImp) GetGaugeMetric(key string) (float64, bool, error) {
 
 func (s *MemStorageImp) UpdateGaugeMetric(metricName string, value float64) error {
 	s.mu.Lock()
-	s.gauges[metricName] = value
+	s.gauges[seriesKey{id: metricName}] = value
+	s.addSample(models.GaugeMetric, seriesKey{id: metricName}, models.Sample{Value: value})
 	s.mu.Unlock()
 	return nil
 }
 
 func (s *MemStorageImp) UpdateCounterMetric(metricName string, value int) error {
+	key := seriesKey{id: metricName}
+
 	s.mu.Lock()
-	s.counters[metricName] += value
+	s.counters[key] += value
+	s.addSample(models.CounterMetric, key, models.Sample{Value: float64(s.counters[key]), Delta: int64(value)})
 	s.mu.Unlock()
 	return nil
 }
 
 func (s *MemStorageImp) GetMetrics() ([]models.Metrics, error) {
+	return s.FindMetrics(models.MetricFilter{})
+}
+
+func (s *MemStorageImp) FindMetrics(filter models.MetricFilter) ([]models.Metrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	allMetrics := []models.Metrics{}
 
 	for key, value := range s.gauges {
 		metric := models.Metrics{
-			ID:    key,
-			MType: "gauge",
-			Value: &value,
+			ID:     key.id,
+			MType:  "gauge",
+			Value:  &value,
+			Labels: models.ParseLabelsKey(key.labels),
 		}
 
-		allMetrics = append(allMetrics, metric)
+		if filter.Matches(metric) {
+			allMetrics = append(allMetrics, metric)
+		}
 	}
 
 	for key, value := range s.counters {
 		metric := models.Metrics{
-			ID:    key,
-			MType: "counter",
+			ID:     key.id,
+			MType:  "counter",
+			Labels: models.ParseLabelsKey(key.labels),
 		}
 
 		val := int64(value)
 		metric.Delta = &val
 
-		allMetrics = append(allMetrics, metric)
+		if filter.Matches(metric) {
+			allMetrics = append(allMetrics, metric)
+		}
 	}
 
 	return allMetrics, nil
 }
 
+func (s *MemStorageImp) GetSeries(id, mType string, labels map[string]string) (models.Metrics, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := newSeriesKey(id, labels)
+	metric := models.Metrics{ID: id, MType: mType, Labels: models.ParseLabelsKey(key.labels)}
+
+	switch mType {
+	case models.GaugeMetric:
+		value, ok := s.gauges[key]
+		if !ok {
+			return models.Metrics{}, false, nil
+		}
+		metric.Value = &value
+	case models.CounterMetric:
+		value, ok := s.counters[key]
+		if !ok {
+			return models.Metrics{}, false, nil
+		}
+		delta := int64(value)
+		metric.Delta = &delta
+	default:
+		return models.Metrics{}, false, nil
+	}
+
+	return metric, true, nil
+}
+
 func (s *MemStorageImp) SetMetrics(allMetrics []models.Metrics) {
 	for _, metric := range allMetrics {
+		key := newSeriesKey(metric.ID, metric.Labels)
 		if metric.MType == models.GaugeMetric {
-			s.gauges[metric.ID] = *metric.Value
+			s.gauges[key] = *metric.Value
 		} else {
-			s.counters[metric.ID] = int(*metric.Delta)
+			s.counters[key] = int(*metric.Delta)
 		}
 	}
 }
@@ -87,12 +135,58 @@ func (s *MemStorageImp) UpdateMetrics(metrics []models.Metrics) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	for _, metric := range metrics {
+		key := newSeriesKey(metric.ID, metric.Labels)
 		if metric.MType == models.GaugeMetric && metric.Value != nil {
-			s.gauges[metric.ID] = *metric.Value
+			s.gauges[key] = *metric.Value
+			s.addSample(models.GaugeMetric, key, models.Sample{Value: *metric.Value})
 		}
 		if metric.MType == models.CounterMetric && metric.Delta != nil {
-			s.counters[metric.ID] += int(*metric.Delta)
+			s.counters[key] += int(*metric.Delta)
+			s.addSample(models.CounterMetric, key, models.Sample{Value: float64(s.counters[key]), Delta: *metric.Delta})
 		}
 	}
 	return nil
 }
+
+func (s *MemStorageImp) GetSamples(query models.SampleQuery) ([]models.Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring, ok := s.history[historyKey{mType: query.MType, series: newSeriesKey(query.ID, query.Labels)}]
+	if !ok {
+		return []models.Sample{}, nil
+	}
+
+	return ring.list(query), nil
+}
+
+// addSample records a time-stamped sample; the caller must hold the mutex.
+func (s *MemStorageImp) addSample(mType string, series seriesKey, sample models.Sample) {
+	key := historyKey{mType: mType, series: series}
+
+	ring, ok := s.history[key]
+	if !ok {
+		ring = newSampleRing(DefaultHistorySize)
+		s.history[key] = ring
+	}
+
+	sample.Timestamp = time.Now()
+	ring.add(sample)
+}
+
+// DeleteSamples deletes the samples recorded before the time and evicts
+// the history of series left without samples.
+func (s *MemStorageImp) DeleteSamples(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, ring := range s.history {
+		deleted += int64(ring.prune(before))
+		if len(ring.samples) == 0 {
+			delete(s.history, key)
+		}
+	}
+
+	return deleted, nil
+}
@@ -5,6 +5,7 @@ import (
 	"alerting-service/internal/utils"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewStorageRepository(t *testing.T) {
@@ -14,7 +15,7 @@ func TestNewStorageRepository(t *testing.T) {
 	}{
 		{
 			name: "new repository test",
-			want: &MemStorageImp{gauges: map[string]float64{}, counters: map[string]int{}},
+			want: &MemStorageImp{gauges: map[seriesKey]float64{}, counters: map[seriesKey]int{}, history: map[historyKey]*sampleRing{}},
 		},
 	}
 
@@ -224,3 +225,127 @@ func TestUpdateMetrics(t *testing.T) {
 		t.Errorf("expected gauge 3.14, got %f", gv)
 	}
 }
+
+func TestGetSamples(t *testing.T) {
+	storage := NewMemStorageRepository()
+	start := time.Now()
+
+	_ = storage.UpdateGaugeMetric("HeapAlloc", 1)
+	_ = storage.UpdateGaugeMetric("HeapAlloc", 2)
+	_ = storage.UpdateCounterMetric("PollCount", 3)
+	_ = storage.UpdateMetrics([]models.Metrics{
+		{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(4)},
+		{ID: "HeapAlloc", MType: models.GaugeMetric, Value: utils.FloatPtr(5)},
+	})
+
+	gauges, err := storage.GetSamples(models.SampleQuery{ID: "HeapAlloc", MType: models.GaugeMetric, Start: start})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gauges) != 3 || gauges[0].Value != 1 || gauges[2].Value != 5 {
+		t.Errorf("unexpected gauge samples: %+v", gauges)
+	}
+
+	counters, err := storage.GetSamples(models.SampleQuery{ID: "PollCount", MType: models.CounterMetric})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(counters) != 2 || counters[1].Value != 7 || counters[1].Delta != 4 {
+		t.Errorf("unexpected counter samples: %+v", counters)
+	}
+
+	future, _ := storage.GetSamples(models.SampleQuery{ID: "HeapAlloc", MType: models.GaugeMetric, Start: time.Now().Add(time.Hour)})
+	if len(future) != 0 {
+		t.Errorf("expected no samples in the future, got %d", len(future))
+	}
+}
+
+func TestDeleteSamples(t *testing.T) {
+	storage := NewMemStorageRepository()
+
+	_ = storage.UpdateGaugeMetric("Idle", 1)
+	_ = storage.UpdateGaugeMetric("Active", 1)
+	cutoff := time.Now()
+	_ = storage.UpdateGaugeMetric("Active", 2)
+
+	deleted, err := storage.DeleteSamples(cutoff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted samples, got %d", deleted)
+	}
+	if _, ok := storage.(*MemStorageImp).history[historyKey{mType: models.GaugeMetric, series: seriesKey{id: "Idle"}}]; ok {
+		t.Error("expected the idle series to be evicted")
+	}
+
+	if idle, _ := storage.GetSamples(models.SampleQuery{ID: "Idle", MType: models.GaugeMetric}); len(idle) != 0 {
+		t.Errorf("expected the idle series to be dropped, got %+v", idle)
+	}
+	if active, _ := storage.GetSamples(models.SampleQuery{ID: "Active", MType: models.GaugeMetric}); len(active) != 1 || active[0].Value != 2 {
+		t.Errorf("expected only the recent sample of the active series, got %+v", active)
+	}
+}
+
+func TestSampleRing_Overwrite(t *testing.T) {
+	ring := newSampleRing(3)
+	ring.add(models.Sample{Value: 1})
+	if len(ring.samples) != 1 {
+		t.Errorf("expected the ring to grow as samples arrive, got %d slots", len(ring.samples))
+	}
+	for i := 2; i <= 5; i++ {
+		ring.add(models.Sample{Value: float64(i)})
+	}
+
+	samples := ring.list(models.SampleQuery{})
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+	for i, want := range []float64{3, 4, 5} {
+		if samples[i].Value != want {
+			t.Errorf("sample %d: want %v, got %v", i, want, samples[i].Value)
+		}
+	}
+}
+
+func TestLabeledSeries(t *testing.T) {
+	storage := NewMemStorageRepository()
+
+	_ = storage.UpdateMetrics([]models.Metrics{
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(1), Labels: map[string]string{"host": "a"}},
+		{ID: "Alloc", MType: models.GaugeMetric, Value: utils.FloatPtr(2), Labels: map[string]string{"host": "b"}},
+		{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(3), Labels: map[string]string{"host": "a"}},
+		{ID: "PollCount", MType: models.CounterMetric, Delta: utils.IntPtr(4), Labels: map[string]string{"host": "a"}},
+	})
+	_ = storage.UpdateGaugeMetric("Alloc", 3)
+
+	metric, ok, _ := storage.GetSeries("Alloc", models.GaugeMetric, map[string]string{"host": "b"})
+	if !ok || *metric.Value != 2 {
+		t.Errorf("expected labeled gauge 2, got %+v", metric)
+	}
+
+	counter, ok, _ := storage.GetSeries("PollCount", models.CounterMetric, map[string]string{"host": "a"})
+	if !ok || *counter.Delta != 7 {
+		t.Errorf("expected labeled counter 7, got %+v", counter)
+	}
+
+	gv, _, _ := storage.GetGaugeMetric("Alloc")
+	if gv != 3 {
+		t.Errorf("expected unlabeled gauge 3, got %f", gv)
+	}
+
+	filtered, _ := storage.FindMetrics(models.MetricFilter{Labels: map[string]string{"host": "a"}})
+	if len(filtered) != 2 {
+		t.Errorf("expected 2 series with host=a, got %d", len(filtered))
+	}
+
+	all, _ := storage.GetMetrics()
+	if len(all) != 4 {
+		t.Errorf("expected 4 series, got %d", len(all))
+	}
+
+	samples, _ := storage.GetSamples(models.SampleQuery{ID: "Alloc", MType: models.GaugeMetric, Labels: map[string]string{"host": "a"}})
+	if len(samples) != 1 || samples[0].Value != 1 {
+		t.Errorf("unexpected labeled samples: %+v", samples)
+	}
+}
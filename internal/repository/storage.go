@@ -2,6 +2,7 @@ package repository
 
 import (
 	"alerting-service/internal/models"
+	"time"
 )
 
 type StorageRepository interface {
@@ -10,6 +11,10 @@ type StorageRepository interface {
 	UpdateGaugeMetric(string, float64) error
 	UpdateCounterMetric(string, int) error
 	GetMetrics() ([]models.Metrics, error)
+	FindMetrics(models.MetricFilter) ([]models.Metrics, error)
+	GetSeries(string, string, map[string]string) (models.Metrics, bool, error)
 	SetMetrics([]models.Metrics)
 	UpdateMetrics([]models.Metrics) error
+	GetSamples(models.SampleQuery) ([]models.Sample, error)
+	DeleteSamples(before time.Time) (int64, error)
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -16,16 +17,28 @@ type ServerImpl struct {
 	httpServer http.Server
 }
 
-func NewServer(addr string) Server {
+// NewServer creates a server listening on addr; with a TLS config it serves
+// HTTPS only.
+func NewServer(addr string, tlsConfig *tls.Config) Server {
 	return &ServerImpl{
 		httpServer: http.Server{
-			Addr: addr,
+			Addr:      addr,
+			TLSConfig: tlsConfig,
 		},
 	}
 }
 
 func (s *ServerImpl) Start(mux *chi.Mux) error {
-	if err := http.ListenAndServe(s.httpServer.Addr, mux); err != http.ErrServerClosed {
+	s.httpServer.Handler = mux
+
+	var err error
+	if s.httpServer.TLSConfig != nil {
+		// The certificate is already in the TLS config.
+		err = s.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = s.httpServer.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		return err
 	}
 
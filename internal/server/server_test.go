@@ -6,7 +6,7 @@ import (
 
 func TestNewServer(t *testing.T) {
 	addr := "localhost:8080"
-	s := NewServer(addr)
+	s := NewServer(addr, nil)
 
 	impl, ok := s.(*ServerImpl)
 	if !ok {
@@ -0,0 +1,157 @@
+package signature
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// TimestampHeader carries the Unix time the request was signed at.
+	TimestampHeader = "X-Signature-Timestamp"
+	// NonceHeader carries a random value unique to the request.
+	NonceHeader = "X-Signature-Nonce"
+
+	DefaultMaxClockSkew = 5 * time.Minute
+
+	nonceCacheSize = 100_000
+	maxNonceLength = 64
+)
+
+var (
+	ErrMissingReplayHeaders = errors.New("signed request has no timestamp or nonce")
+	ErrStaleTimestamp       = errors.New("signed request timestamp is outside the allowed clock skew")
+	ErrReplayedNonce        = errors.New("signed request nonce was already used")
+)
+
+// maxClockSkew is how far the request timestamp may be from the server
+// clock; it can be replaced while the server runs.
+var maxClockSkew atomic.Int64
+
+// seenNonces holds the nonces of the accepted requests until their
+// timestamp falls out of the skew window.
+var seenNonces = newNonceCache(nonceCacheSize)
+
+func init() {
+	SetMaxClockSkew(DefaultMaxClockSkew)
+}
+
+func SetMaxClockSkew(skew time.Duration) {
+	maxClockSkew.Store(int64(skew))
+}
+
+// GetRequestHash returns the signature of a request, which covers the
+// timestamp and the nonce as well as the body.
+func GetRequestHash(body []byte, hashKey []byte, timestamp, nonce string) string {
+	data := make([]byte, 0, len(timestamp)+len(nonce)+2+len(body))
+	data = append(data, timestamp...)
+	data = append(data, '\n')
+	data = append(data, nonce...)
+	data = append(data, '\n')
+	data = append(data, body...)
+	return GetHash(data, hashKey)
+}
+
+// SignRequest sets the signature, timestamp and nonce headers of the request.
+func SignRequest(req *http.Request, body []byte, hashKey []byte) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	encodedNonce := hex.EncodeToString(nonce)
+
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, encodedNonce)
+	req.Header.Set(HashSHA256, GetRequestHash(body, hashKey, timestamp, encodedNonce))
+	return nil
+}
+
+// checkReplay rejects requests signed outside the skew window and nonces
+// that were already used. It must be called only after the signature is
+// verified, so that unsigned requests cannot fill the nonce cache.
+func checkReplay(timestamp, nonce string) error {
+	if timestamp == "" || nonce == "" || len(nonce) > maxNonceLength {
+		return ErrMissingReplayHeaders
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingReplayHeaders
+	}
+
+	skew := time.Duration(maxClockSkew.Load())
+	signedAt := time.Unix(seconds, 0)
+	current := time.Now()
+	if signedAt.Before(current.Add(-skew)) || signedAt.After(current.Add(skew)) {
+		return ErrStaleTimestamp
+	}
+
+	if !seenNonces.add(nonce, signedAt.Add(skew), current) {
+		return ErrReplayedNonce
+	}
+	return nil
+}
+
+// nonceCache is a bounded set of nonces. Nonces are dropped once expired
+// or, when the cache is full, oldest first.
+type nonceCache struct {
+	mu      sync.Mutex
+	entries map[string]nonceEntry
+	order   []string // ring buffer of the nonces in insertion order
+	head    int
+	size    int
+}
+
+// nonceEntry is the expiry of a nonce and its position in the ring.
+type nonceEntry struct {
+	expires time.Time
+	slot    int
+}
+
+func newNonceCache(capacity int) *nonceCache {
+	return &nonceCache{
+		entries: make(map[string]nonceEntry, capacity),
+		order:   make([]string, capacity),
+	}
+}
+
+// add records the nonce until expires and reports whether it was unused.
+// An expired nonce that is used again moves to the newest position, so it
+// is not evicted ahead of older nonces.
+func (c *nonceCache) add(nonce string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[nonce]; ok {
+		if now.Before(entry.expires) {
+			return false
+		}
+		// The emptied slot is dropped when it becomes the oldest.
+		c.order[entry.slot] = ""
+		delete(c.entries, nonce)
+	}
+
+	for c.size > 0 {
+		oldest := c.order[c.head]
+		if entry, ok := c.entries[oldest]; ok && c.size < len(c.order) && now.Before(entry.expires) {
+			break
+		}
+		delete(c.entries, oldest)
+		c.order[c.head] = ""
+		c.head = (c.head + 1) % len(c.order)
+		c.size--
+	}
+
+	slot := (c.head + c.size) % len(c.order)
+	c.order[slot] = nonce
+	c.entries[nonce] = nonceEntry{expires: expires, slot: slot}
+	c.size++
+	return true
+}
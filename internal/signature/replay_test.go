@@ -0,0 +1,127 @@
+package signature
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHashMiddleware_Replay(t *testing.T) {
+	SetServerHashKey("secret")
+	SetMaxClockSkew(time.Minute)
+	defer SetMaxClockSkew(DefaultMaxClockSkew)
+
+	body := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+	handler := HashMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(timestamp, nonce string) int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(NonceHeader, nonce)
+		req.Header.Set(HashSHA256, GetRequestHash(body, []byte("secret"), timestamp, nonce))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	current := strconv.FormatInt(time.Now().Unix(), 10)
+	if code := send(current, "replay-first"); code != http.StatusOK {
+		t.Fatalf("expected first request to be accepted, got %d", code)
+	}
+	if code := send(current, "replay-first"); code != http.StatusBadRequest {
+		t.Errorf("expected replayed nonce to be rejected, got %d", code)
+	}
+
+	stale := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	if code := send(stale, "replay-stale"); code != http.StatusBadRequest {
+		t.Errorf("expected stale timestamp to be rejected, got %d", code)
+	}
+
+	future := strconv.FormatInt(time.Now().Add(2*time.Minute).Unix(), 10)
+	if code := send(future, "replay-future"); code != http.StatusBadRequest {
+		t.Errorf("expected future timestamp to be rejected, got %d", code)
+	}
+
+	if code := send("", ""); code != http.StatusBadRequest {
+		t.Errorf("expected request without timestamp and nonce to be rejected, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+	req.Header.Set(TimestampHeader, current)
+	req.Header.Set(NonceHeader, "replay-tampered")
+	req.Header.Set(HashSHA256, GetRequestHash(body, []byte("secret"), current, "replay-first"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || rec.Body.String() != "invalid hash\n" {
+		t.Errorf("expected nonce outside the signature to be rejected, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNonceCache(t *testing.T) {
+	cache := newNonceCache(2)
+	start := time.Now()
+
+	if !cache.add("a", start.Add(time.Minute), start) || cache.add("a", start.Add(time.Minute), start) {
+		t.Fatal("expected a nonce to be accepted once")
+	}
+
+	cache.add("b", start.Add(time.Minute), start)
+	cache.add("c", start.Add(time.Minute), start)
+	if len(cache.entries) != 2 {
+		t.Errorf("expected the cache to stay bounded, got %d nonces", len(cache.entries))
+	}
+	if !cache.add("a", start.Add(time.Minute), start) {
+		t.Error("expected the oldest nonce to be evicted when full")
+	}
+
+	later := start.Add(2 * time.Minute)
+	if !cache.add("d", later.Add(time.Minute), later) || len(cache.entries) != 1 {
+		t.Errorf("expected expired nonces to be dropped, got %v", cache.entries)
+	}
+}
+
+func TestNonceCache_ReusedNonceMovesToNewest(t *testing.T) {
+	cache := newNonceCache(3)
+	start := time.Now()
+
+	cache.add("a", start.Add(time.Minute), start)
+	cache.add("b", start.Add(10*time.Minute), start)
+
+	later := start.Add(2 * time.Minute)
+	if !cache.add("a", later.Add(time.Minute), later) {
+		t.Fatal("expected an expired nonce to be accepted again")
+	}
+	cache.add("c", later.Add(time.Minute), later)
+	cache.add("d", later.Add(time.Minute), later)
+
+	if cache.add("a", later.Add(time.Minute), later) {
+		t.Error("expected the reused nonce to outlive older nonces")
+	}
+	if !cache.add("b", later.Add(time.Minute), later) {
+		t.Error("expected the oldest nonce to be evicted when full")
+	}
+}
+
+func TestHashMiddleware_Unsigned(t *testing.T) {
+	SetServerHashKey("secret")
+	defer SetServerHashKey("")
+
+	handler := HashMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader([]byte(`[{"id":"Evil","type":"counter","delta":1000}]`)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected unsigned update to be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/value/counter/Evil", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected unsigned GET to be served, got %d", rec.Code)
+	}
+}
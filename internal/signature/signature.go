@@ -7,14 +7,28 @@ import (
 	"encoding/hex"
 	"io"
 	"net/http"
+	"sync/atomic"
+
+	"alerting-service/internal/logger"
+
+	"go.uber.org/zap"
 )
 
 var HashSHA256 = "HashSHA256"
 
-var hashKey []byte
+// hashKey holds the server key; it can be replaced while the server runs.
+var hashKey atomic.Pointer[[]byte]
 
 func SetServerHashKey(key string) {
-	hashKey = []byte(key)
+	value := []byte(key)
+	hashKey.Store(&value)
+}
+
+func serverHashKey() []byte {
+	if key := hashKey.Load(); key != nil {
+		return *key
+	}
+	return nil
 }
 
 func GetHash(data []byte, hashKey []byte) string {
@@ -23,25 +37,87 @@ func GetHash(data []byte, hashKey []byte) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// HashMiddleware verifies the request signature and signs every response
+// with the HashSHA256 header when the server has a hash key.
 func HashMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		if string(hashKey) == "" || req.Header.Get(HashSHA256) == "" {
+		hashKey := serverHashKey()
+		if len(hashKey) == 0 {
 			next.ServeHTTP(w, req)
 			return
 		}
-		bodyBytes, err := io.ReadAll(req.Body)
-		if err != nil {
-			http.Error(w, "Unable to read request body", http.StatusInternalServerError)
-			return
-		}
-		req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		expectedHash := GetHash(bodyBytes, hashKey)
-		receivedHash := req.Header.Get(HashSHA256)
 
-		if !hmac.Equal([]byte(expectedHash), []byte(receivedHash)) {
-			http.Error(w, "invalid hash", http.StatusBadRequest)
+		hw := &hashWriter{ResponseWriter: w}
+		verifyRequest(hw, req, hashKey, next)
+		hw.flush(hashKey)
+	})
+}
+
+// verifyRequest passes requests on only if they are signed with the hash
+// key. GET and HEAD requests carry no body and change nothing, so they are
+// not required to be signed.
+func verifyRequest(w http.ResponseWriter, req *http.Request, hashKey []byte, next http.Handler) {
+	if req.Header.Get(HashSHA256) == "" {
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			next.ServeHTTP(w, req)
 			return
 		}
-		next.ServeHTTP(w, req)
-	})
+		http.Error(w, "missing signature", http.StatusBadRequest)
+		return
+	}
+	bodyBytes, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, "Unable to read request body", http.StatusInternalServerError)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	timestamp := req.Header.Get(TimestampHeader)
+	nonce := req.Header.Get(NonceHeader)
+	expectedHash := GetRequestHash(bodyBytes, hashKey, timestamp, nonce)
+	receivedHash := req.Header.Get(HashSHA256)
+
+	if !hmac.Equal([]byte(expectedHash), []byte(receivedHash)) {
+		http.Error(w, "invalid hash", http.StatusBadRequest)
+		return
+	}
+
+	if err := checkReplay(timestamp, nonce); err != nil {
+		logger.Log.Warn("Rejected signed request", zap.String("remote_addr", req.RemoteAddr), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	next.ServeHTTP(w, req)
+}
+
+// hashWriter buffers the response so that its signature can be set in the
+// HashSHA256 header before the body is written.
+type hashWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (h *hashWriter) Write(p []byte) (int, error) {
+	return h.body.Write(p)
+}
+
+func (h *hashWriter) WriteHeader(statusCode int) {
+	if h.status == 0 {
+		h.status = statusCode
+	}
+}
+
+// flush signs the buffered body and writes the response.
+func (h *hashWriter) flush(hashKey []byte) {
+	h.ResponseWriter.Header().Set(HashSHA256, GetHash(h.body.Bytes(), hashKey))
+	if h.status != 0 {
+		h.ResponseWriter.WriteHeader(h.status)
+	}
+	_, _ = h.ResponseWriter.Write(h.body.Bytes())
+}
+
+// VerifyResponse reports whether the HashSHA256 header of a response is the
+// signature of its decoded body.
+func VerifyResponse(header http.Header, body []byte, hashKey []byte) bool {
+	return hmac.Equal([]byte(GetHash(body, hashKey)), []byte(header.Get(HashSHA256)))
 }
@@ -12,14 +12,15 @@ func TestHashMiddleware_ValidHash(t *testing.T) {
 	SetServerHashKey("secret")
 
 	body := []byte(`{"test":"data"}`)
-	hash := GetHash(body, []byte("secret"))
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
 	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
-	req.Header.Set(HashSHA256, hash)
+	if err := SignRequest(req, body, []byte("secret")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	rec := httptest.NewRecorder()
 	HashMiddleware(handler).ServeHTTP(rec, req)
@@ -70,3 +71,29 @@ func TestGetHash_Deterministic(t *testing.T) {
 		t.Errorf("expected hashes to match: %s != %s", hash1, hash2)
 	}
 }
+
+func TestHashMiddleware_SignsResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+
+	SetServerHashKey("secret")
+	rec := httptest.NewRecorder()
+	HashMiddleware(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	if !VerifyResponse(rec.Header(), rec.Body.Bytes(), []byte("secret")) {
+		t.Errorf("unexpected response signature %q", rec.Header().Get(HashSHA256))
+	}
+
+	SetServerHashKey("")
+	rec = httptest.NewRecorder()
+	HashMiddleware(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Header().Get(HashSHA256) != "" {
+		t.Error("expected responses not to be signed without a hash key")
+	}
+}
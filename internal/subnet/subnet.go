@@ -0,0 +1,106 @@
+// Package subnet restricts metric updates to agents from a trusted subnet.
+//
+// The agent address is taken from the X-Real-IP header, which the agent sets
+// to its own address. Any client can send the header, so the check only
+// holds when the server sits behind a reverse proxy that overwrites
+// X-Real-IP with the peer address, or on a network where only agents can
+// reach the server.
+package subnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"alerting-service/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// RealIPHeader carries the address of the agent that sent the request.
+const RealIPHeader = "X-Real-IP"
+
+var ErrInvalidSubnet = errors.New("invalid trusted subnet: must be a CIDR like 10.0.0.0/8")
+
+// Parse parses the trusted subnet CIDR; an empty string means any address
+// is trusted and returns nil.
+func Parse(cidr string) (*net.IPNet, error) {
+	if cidr == "" {
+		return nil, nil
+	}
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("%w, got %q", ErrInvalidSubnet, cidr)
+	}
+
+	return subnet, nil
+}
+
+// Trusted holds the trusted subnet, which can be replaced while the server
+// is running.
+type Trusted struct {
+	subnet atomic.Pointer[net.IPNet]
+}
+
+// NewTrusted creates a holder with the subnet; a nil subnet trusts any address.
+func NewTrusted(subnet *net.IPNet) *Trusted {
+	trusted := &Trusted{}
+	trusted.Store(subnet)
+	return trusted
+}
+
+// Load returns the current subnet.
+func (t *Trusted) Load() *net.IPNet {
+	return t.subnet.Load()
+}
+
+// Store replaces the subnet.
+func (t *Trusted) Store(subnet *net.IPNet) {
+	t.subnet.Store(subnet)
+}
+
+// Middleware rejects requests from outside the trusted subnet with 403.
+// The client address is taken from the X-Real-IP header set by the agent,
+// or from the peer address when the header is missing. The header is not
+// verified; see the package comment.
+func Middleware(trusted *Trusted) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subnet := trusted.Load()
+			if subnet == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := clientIP(r)
+			if ip == nil || !subnet.Contains(ip) {
+				logger.Log.Warn("Rejected request from untrusted address",
+					zap.String("real_ip", r.Header.Get(RealIPHeader)),
+					zap.String("remote_addr", r.RemoteAddr))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address from the X-Real-IP header or the peer
+// address, or nil if it cannot be parsed. The header takes precedence, so
+// a client can claim any address unless a proxy overwrites it.
+func clientIP(r *http.Request) net.IP {
+	if realIP := r.Header.Get(RealIPHeader); realIP != "" {
+		return net.ParseIP(realIP)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
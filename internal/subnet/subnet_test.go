@@ -0,0 +1,65 @@
+package subnet
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	if subnet, err := Parse(""); subnet != nil || err != nil {
+		t.Errorf("expected nil subnet for empty CIDR, got %v, %v", subnet, err)
+	}
+	if _, err := Parse("10.0.0.1"); !errors.Is(err, ErrInvalidSubnet) {
+		t.Errorf("expected ErrInvalidSubnet, got %v", err)
+	}
+	if subnet, err := Parse("10.0.0.0/8"); err != nil || subnet.String() != "10.0.0.0/8" {
+		t.Errorf("unexpected subnet %v, %v", subnet, err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	subnet, _ := Parse("192.168.1.0/24")
+
+	tests := []struct {
+		name       string
+		subnet     string
+		realIP     string
+		remoteAddr string
+		want       int
+	}{
+		{name: "header inside", subnet: "192.168.1.0/24", realIP: "192.168.1.10", remoteAddr: "10.0.0.1:5000", want: http.StatusOK},
+		{name: "header outside", subnet: "192.168.1.0/24", realIP: "10.0.0.1", remoteAddr: "192.168.1.10:5000", want: http.StatusForbidden},
+		{name: "invalid header", subnet: "192.168.1.0/24", realIP: "localhost", remoteAddr: "192.168.1.10:5000", want: http.StatusForbidden},
+		{name: "peer inside", subnet: "192.168.1.0/24", remoteAddr: "192.168.1.10:5000", want: http.StatusOK},
+		{name: "peer outside", subnet: "192.168.1.0/24", remoteAddr: "10.0.0.1:5000", want: http.StatusForbidden},
+		{name: "no subnet", remoteAddr: "10.0.0.1:5000", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted := NewTrusted(nil)
+			if tt.subnet != "" {
+				trusted.Store(subnet)
+			}
+
+			handler := Middleware(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set(RealIPHeader, tt.realIP)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
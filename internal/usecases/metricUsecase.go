@@ -14,7 +14,9 @@ type MetricUsecase interface {
 	MetricDataProcessing(models.Metrics) error
 	GetMetricDataProcessing(models.Metrics) (float64, error)
 	GetMetrics() ([]models.Metrics, error)
+	FindMetrics(map[string]string) ([]models.Metrics, error)
 	UpdateMetrics([]models.Metrics) error
+	QueryRange(models.RangeQuery) ([]models.Point, error)
 }
 
 type MetricUsecaseImpl struct {
@@ -28,6 +30,10 @@ func NewMetricUsecase(storageRepository repository.StorageRepository) MetricUsec
 }
 
 func (usecase *MetricUsecaseImpl) MetricDataProcessing(metric models.Metrics) error {
+	if len(metric.Labels) > 0 {
+		return usecase.storageRepository.UpdateMetrics([]models.Metrics{metric})
+	}
+
 	switch metric.MType {
 	case models.CounterMetric:
 		usecase.storageRepository.UpdateCounterMetric(metric.ID, int(*metric.Delta))
@@ -39,6 +45,10 @@ func (usecase *MetricUsecaseImpl) MetricDataProcessing(metric models.Metrics) er
 }
 
 func (usecase *MetricUsecaseImpl) GetMetricDataProcessing(metric models.Metrics) (float64, error) {
+	if len(metric.Labels) > 0 {
+		return usecase.getSeriesValue(metric)
+	}
+
 	switch metric.MType {
 	case models.CounterMetric:
 		if value, ok, _ := usecase.storageRepository.GetCounterMetric(metric.ID); ok {
@@ -56,6 +66,25 @@ func (usecase *MetricUsecaseImpl) GetMetricDataProcessing(metric models.Metrics)
 	return 0, v.ErrInvalidMetricValue
 }
 
+func (usecase *MetricUsecaseImpl) getSeriesValue(metric models.Metrics) (float64, error) {
+	if metric.MType != models.CounterMetric && metric.MType != models.GaugeMetric {
+		return 0, v.ErrInvalidMetricValue
+	}
+
+	series, ok, err := usecase.storageRepository.GetSeries(metric.ID, metric.MType, metric.Labels)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, v.ErrMetricNotFound
+	}
+
+	if series.MType == models.CounterMetric {
+		return float64(*series.Delta), nil
+	}
+	return *series.Value, nil
+}
+
 func (usecase *MetricUsecaseImpl) GetMetrics() ([]models.Metrics, error) {
 	metrics, err := usecase.storageRepository.GetMetrics()
 	if err != nil {
@@ -64,6 +93,10 @@ func (usecase *MetricUsecaseImpl) GetMetrics() ([]models.Metrics, error) {
 	return metrics, nil
 }
 
+func (usecase *MetricUsecaseImpl) FindMetrics(labels map[string]string) ([]models.Metrics, error) {
+	return usecase.storageRepository.FindMetrics(models.MetricFilter{Labels: labels})
+}
+
 func (usecase *MetricUsecaseImpl) UpdateMetrics(metrics []models.Metrics) error {
 	logger.Log.Debug("Entering UpdateMetrics in usecase", zap.Int("metrics_count", len(metrics)))
 
@@ -186,3 +186,25 @@ func TestUpdateMetrics(t *testing.T) {
 		t.Errorf("expected 2 metrics, got %d", len(all))
 	}
 }
+
+func TestLabeledMetricDataProcessing(t *testing.T) {
+	usecase := NewMetricUsecase(repository.NewMemStorageRepository())
+
+	labels := map[string]string{"host": "a"}
+	_ = usecase.MetricDataProcessing(models.Metrics{MType: "counter", ID: "hits", Delta: utils.IntPtr(2), Labels: labels})
+	_ = usecase.MetricDataProcessing(models.Metrics{MType: "counter", ID: "hits", Delta: utils.IntPtr(3), Labels: labels})
+
+	got, err := usecase.GetMetricDataProcessing(models.Metrics{MType: "counter", ID: "hits", Labels: labels})
+	if err != nil || got != 5 {
+		t.Errorf("want 5, got %v (err %v)", got, err)
+	}
+
+	if _, err := usecase.GetMetricDataProcessing(models.Metrics{MType: "counter", ID: "hits"}); err != v.ErrMetricNotFound {
+		t.Errorf("want unlabeled series to be missing, got %v", err)
+	}
+
+	found, _ := usecase.FindMetrics(labels)
+	if len(found) != 1 {
+		t.Errorf("expected 1 series, got %d", len(found))
+	}
+}
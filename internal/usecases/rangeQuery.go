@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	"alerting-service/internal/models"
+	"math"
+	"slices"
+	"time"
+
+	v "alerting-service/internal/validation"
+)
+
+// QueryRange returns the samples of a metric aggregated into points aligned
+// to multiples of the step since the Unix epoch. Steps without samples are
+// omitted. For counters, "sum" adds up the increments within a step while the
+// other aggregations work on the counter total.
+func (usecase *MetricUsecaseImpl) QueryRange(query models.RangeQuery) ([]models.Point, error) {
+	if query.Aggregation == "" {
+		query.Aggregation = models.AggregationLast
+	}
+
+	if err := validateRangeQuery(query); err != nil {
+		return nil, err
+	}
+
+	samples, err := usecase.storageRepository.GetSamples(models.SampleQuery{
+		ID:     query.ID,
+		MType:  query.MType,
+		Labels: query.Labels,
+		Start:  query.Start,
+		End:    query.End,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	points := []models.Point{}
+	var bucket []float64
+	var bucketStart time.Time
+
+	flush := func() {
+		if len(bucket) > 0 {
+			points = append(points, models.Point{Timestamp: bucketStart, Value: aggregate(query.Aggregation, bucket)})
+		}
+		bucket = bucket[:0]
+	}
+
+	useDelta := query.MType == models.CounterMetric && query.Aggregation == models.AggregationSum
+
+	for _, sample := range samples {
+		start := alignToStep(sample.Timestamp, query.Step)
+		if !start.Equal(bucketStart) {
+			flush()
+			bucketStart = start
+		}
+
+		if useDelta {
+			bucket = append(bucket, float64(sample.Delta))
+		} else {
+			bucket = append(bucket, sample.Value)
+		}
+	}
+	flush()
+
+	return points, nil
+}
+
+func validateRangeQuery(query models.RangeQuery) error {
+	if query.ID == "" || query.Step <= 0 || query.Start.IsZero() || query.End.IsZero() || query.End.Before(query.Start) {
+		return v.ErrInvalidRangeQuery
+	}
+	if !slices.Contains(v.ValidMetricTypes, query.MType) {
+		return v.ErrInvalidMetricType
+	}
+	if !slices.Contains(v.ValidAggregations, query.Aggregation) {
+		return v.ErrInvalidAggregation
+	}
+	if query.End.Sub(query.Start)/query.Step >= time.Duration(v.MaxRangePoints) {
+		return v.ErrTooManyPoints
+	}
+
+	return nil
+}
+
+func alignToStep(ts time.Time, step time.Duration) time.Time {
+	nanos := ts.UnixNano()
+	return time.Unix(0, nanos-nanos%int64(step)).In(ts.Location())
+}
+
+func aggregate(aggregation string, values []float64) float64 {
+	switch aggregation {
+	case models.AggregationAvg:
+		var sum float64
+		for _, value := range values {
+			sum += value
+		}
+		return sum / float64(len(values))
+	case models.AggregationMin:
+		result := math.Inf(1)
+		for _, value := range values {
+			result = math.Min(result, value)
+		}
+		return result
+	case models.AggregationMax:
+		result := math.Inf(-1)
+		for _, value := range values {
+			result = math.Max(result, value)
+		}
+		return result
+	case models.AggregationSum:
+		var sum float64
+		for _, value := range values {
+			sum += value
+		}
+		return sum
+	}
+
+	return values[len(values)-1]
+}
@@ -0,0 +1,104 @@
+package usecases
+
+import (
+	"alerting-service/internal/models"
+	"alerting-service/internal/repository"
+	v "alerting-service/internal/validation"
+	"testing"
+	"time"
+)
+
+type historyRepositoryStub struct {
+	repository.StorageRepository
+	samples []models.Sample
+}
+
+func (r *historyRepositoryStub) GetSamples(models.SampleQuery) ([]models.Sample, error) {
+	return r.samples, nil
+}
+
+func newRangeUsecase(samples []models.Sample) *MetricUsecaseImpl {
+	return &MetricUsecaseImpl{storageRepository: &historyRepositoryStub{samples: samples}}
+}
+
+func TestQueryRange_Aggregations(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	samples := []models.Sample{
+		{Timestamp: base, Value: 1, Delta: 1},
+		{Timestamp: base.Add(5 * time.Second), Value: 4, Delta: 3},
+		{Timestamp: base.Add(9 * time.Second), Value: 6, Delta: 2},
+		{Timestamp: base.Add(25 * time.Second), Value: 10, Delta: 4},
+	}
+
+	tests := []struct {
+		aggregation string
+		mType       string
+		want        []float64
+	}{
+		{aggregation: models.AggregationAvg, mType: models.GaugeMetric, want: []float64{11.0 / 3, 10}},
+		{aggregation: models.AggregationMin, mType: models.GaugeMetric, want: []float64{1, 10}},
+		{aggregation: models.AggregationMax, mType: models.GaugeMetric, want: []float64{6, 10}},
+		{aggregation: models.AggregationLast, mType: models.GaugeMetric, want: []float64{6, 10}},
+		{aggregation: models.AggregationSum, mType: models.GaugeMetric, want: []float64{11, 10}},
+		{aggregation: models.AggregationSum, mType: models.CounterMetric, want: []float64{6, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mType+"_"+test.aggregation, func(t *testing.T) {
+			usecase := newRangeUsecase(samples)
+
+			points, err := usecase.QueryRange(models.RangeQuery{
+				ID:          "m",
+				MType:       test.mType,
+				Start:       base,
+				End:         base.Add(time.Minute),
+				Step:        10 * time.Second,
+				Aggregation: test.aggregation,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(points) != len(test.want) {
+				t.Fatalf("want %d points, got %d", len(test.want), len(points))
+			}
+			for i, want := range test.want {
+				if points[i].Value != want {
+					t.Errorf("point %d: want %v, got %v", i, want, points[i].Value)
+				}
+				if points[i].Timestamp.UnixNano()%int64(10*time.Second) != 0 {
+					t.Errorf("point %d is not aligned to step: %s", i, points[i].Timestamp)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryRange_Validation(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	valid := models.RangeQuery{ID: "m", MType: models.GaugeMetric, Start: base, End: base.Add(time.Minute), Step: time.Second, Aggregation: models.AggregationAvg}
+
+	tests := []struct {
+		name    string
+		modify  func(q *models.RangeQuery)
+		wantErr error
+	}{
+		{name: "end before start", modify: func(q *models.RangeQuery) { q.End = base.Add(-time.Second) }, wantErr: v.ErrInvalidRangeQuery},
+		{name: "zero step", modify: func(q *models.RangeQuery) { q.Step = 0 }, wantErr: v.ErrInvalidRangeQuery},
+		{name: "invalid type", modify: func(q *models.RangeQuery) { q.MType = "histogram" }, wantErr: v.ErrInvalidMetricType},
+		{name: "invalid aggregation", modify: func(q *models.RangeQuery) { q.Aggregation = "median" }, wantErr: v.ErrInvalidAggregation},
+		{name: "too many points", modify: func(q *models.RangeQuery) { q.Step = time.Millisecond }, wantErr: v.ErrTooManyPoints},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := valid
+			test.modify(&query)
+
+			_, err := newRangeUsecase(nil).QueryRange(query)
+			if err != test.wantErr {
+				t.Errorf("want error: %v, got: %v", test.wantErr, err)
+			}
+		})
+	}
+}
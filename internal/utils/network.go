@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"net"
+)
+
+// OutboundIP returns the local address used to reach the given host:port.
+// No packets are sent: dialing UDP only selects a route.
+func OutboundIP(addr string) (net.IP, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alerting-service/internal/models"
+	v "alerting-service/internal/validation"
+)
+
+// ParseRangeQuery builds a range query from the id, type, start, end, step
+// agg and label URL query parameters; agg defaults to "last". Timestamps are RFC 3339 or Unix seconds,
+// the step is a Go duration ("15s") or a number of seconds.
+func ParseRangeQuery(values url.Values) (models.RangeQuery, error) {
+	var q models.RangeQuery
+
+	start, err := parseTimestamp(values.Get("start"))
+	if err != nil {
+		return q, v.ErrInvalidRangeQuery
+	}
+
+	end, err := parseTimestamp(values.Get("end"))
+	if err != nil {
+		return q, v.ErrInvalidRangeQuery
+	}
+
+	step, err := parseStep(values.Get("step"))
+	if err != nil {
+		return q, v.ErrInvalidRangeQuery
+	}
+
+	labels, err := ParseLabelFilter(values)
+	if err != nil {
+		return q, err
+	}
+
+	q.ID = values.Get("id")
+	q.Labels = labels
+	q.MType = values.Get("type")
+	q.Start = start
+	q.End = end
+	q.Step = step
+	q.Aggregation = values.Get("agg")
+	if q.Aggregation == "" {
+		q.Aggregation = models.AggregationLast
+	}
+
+	return q, nil
+}
+
+// ParseLabelFilter collects the repeated label=<name>=<value> URL query
+// parameters into a label set.
+func ParseLabelFilter(values url.Values) (map[string]string, error) {
+	var labels map[string]string
+
+	for _, pair := range values["label"] {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, v.ErrInvalidLabelFilter
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[name] = value
+	}
+
+	return labels, nil
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return ts, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*float64(time.Second))), nil
+}
+
+func parseStep(value string) (time.Duration, error) {
+	if step, err := time.ParseDuration(value); err == nil {
+		return step, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"alerting-service/internal/models"
+	v "alerting-service/internal/validation"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRangeQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		want     models.RangeQuery
+		wantErr  error
+	}{
+		{
+			name:     "unix seconds and duration step",
+			rawQuery: "id=HeapAlloc&type=gauge&start=1700000000&end=1700000060&step=15s&agg=avg",
+			want: models.RangeQuery{
+				ID:          "HeapAlloc",
+				MType:       "gauge",
+				Start:       time.Unix(1700000000, 0),
+				End:         time.Unix(1700000060, 0),
+				Step:        15 * time.Second,
+				Aggregation: "avg",
+			},
+		},
+		{
+			name:     "rfc3339 and seconds step with default aggregation",
+			rawQuery: "id=PollCount&type=counter&start=2024-01-01T00:00:00Z&end=2024-01-01T01:00:00Z&step=60",
+			want: models.RangeQuery{
+				ID:          "PollCount",
+				MType:       "counter",
+				Start:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				End:         time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+				Step:        time.Minute,
+				Aggregation: "last",
+			},
+		},
+		{
+			name:     "missing step",
+			rawQuery: "id=HeapAlloc&type=gauge&start=1700000000&end=1700000060",
+			wantErr:  v.ErrInvalidRangeQuery,
+		},
+		{
+			name:     "invalid start",
+			rawQuery: "id=HeapAlloc&type=gauge&start=yesterday&end=1700000060&step=15s",
+			wantErr:  v.ErrInvalidRangeQuery,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(test.rawQuery)
+			got, err := ParseRangeQuery(values)
+
+			if err != test.wantErr {
+				t.Fatalf("want error: %v, got: %v", test.wantErr, err)
+			}
+			if test.wantErr != nil {
+				return
+			}
+			if got.ID != test.want.ID || got.MType != test.want.MType || got.Step != test.want.Step || got.Aggregation != test.want.Aggregation {
+				t.Errorf("want: %+v, got: %+v", test.want, got)
+			}
+			if !got.Start.Equal(test.want.Start) || !got.End.Equal(test.want.End) {
+				t.Errorf("want range %s - %s, got %s - %s", test.want.Start, test.want.End, got.Start, got.End)
+			}
+		})
+	}
+}
+
+func TestParseLabelFilter(t *testing.T) {
+	values, _ := url.ParseQuery("label=host=web1&label=query=a=b")
+
+	labels, err := ParseLabelFilter(values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(labels) != 2 || labels["host"] != "web1" || labels["query"] != "a=b" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+
+	values, _ = url.ParseQuery("label=host")
+	if _, err := ParseLabelFilter(values); err != v.ErrInvalidLabelFilter {
+		t.Errorf("want error: %v, got: %v", v.ErrInvalidLabelFilter, err)
+	}
+}
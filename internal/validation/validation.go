@@ -12,6 +12,10 @@ var (
 	ErrInvalidMetricValue = errors.New("invalid metric value")
 	ErrMethodNotAllowed   = errors.New("method not allowed")
 	ErrDBNotAvailable     = errors.New("database is not available")
+	ErrInvalidRangeQuery  = errors.New("invalid range query")
+	ErrInvalidAggregation = errors.New("invalid aggregation")
+	ErrTooManyPoints      = errors.New("range query exceeds maximum number of points")
+	ErrInvalidLabelFilter = errors.New("invalid label filter")
 )
 
 var ErrMap = map[error]int{
@@ -20,8 +24,14 @@ var ErrMap = map[error]int{
 	ErrInvalidMetricValue: http.StatusBadRequest,
 	ErrMethodNotAllowed:   http.StatusMethodNotAllowed,
 	ErrDBNotAvailable:     http.StatusInternalServerError,
+	ErrInvalidRangeQuery:  http.StatusBadRequest,
+	ErrInvalidAggregation: http.StatusBadRequest,
+	ErrTooManyPoints:      http.StatusBadRequest,
+	ErrInvalidLabelFilter: http.StatusBadRequest,
 }
 
 var ValidMetricTypes = []string{models.CounterMetric, models.GaugeMetric}
 var ValidCountUpdateURLParts = 5
 var ValidCountGetURLParts = 4
+var ValidAggregations = []string{models.AggregationAvg, models.AggregationMin, models.AggregationMax, models.AggregationLast, models.AggregationSum}
+var MaxRangePoints = 11000